@@ -0,0 +1,95 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package aws contains validators for the identifier formats AWS assigns
+// to accounts, IAM roles, regions and ARNs. They're format checks only —
+// they don't call out to AWS, so they can't tell you a given identifier
+// actually exists.
+package aws
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// accountIDRegex matches an AWS account id: exactly 12 ASCII digits.
+var accountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+
+// IsValidAccountID checks if the accountID is a valid AWS account ID.
+//
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_identifiers.html
+func IsValidAccountID(accountID string) error {
+	if !accountIDRegex.MatchString(accountID) {
+		return trace.BadParameter("invalid AWS account ID %q", accountID)
+	}
+	return nil
+}
+
+// iamRoleNameRegex matches the charset IAM allows in a role name.
+var iamRoleNameRegex = regexp.MustCompile(`^[A-Za-z0-9+=,.@_-]+$`)
+
+// maxIAMRoleNameLength is the longest name IAM accepts for a role.
+const maxIAMRoleNameLength = 64
+
+// IsValidIAMRoleName checks if the role is a valid IAM Role name.
+//
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_CreateRole.html#API_CreateRole_RequestParameters
+func IsValidIAMRoleName(role string) error {
+	if len(role) == 0 || len(role) > maxIAMRoleNameLength || !iamRoleNameRegex.MatchString(role) {
+		return trace.BadParameter("invalid AWS IAM role name %q", role)
+	}
+	return nil
+}
+
+// regionRegex matches an AWS-style region: a two-letter country code,
+// followed by one or more "-word" segments, followed by a "-number"
+// suffix (eg us-east-1, us-gov-east-1, xx-iso-somewhere-100).
+var regionRegex = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// IsValidRegion checks if the region is a valid AWS Region name.
+//
+// https://docs.aws.amazon.com/AmazonRDS/latest/UserGuide/Concepts.RegionsAndAvailabilityZones.html
+func IsValidRegion(region string) error {
+	if !regionRegex.MatchString(region) {
+		return trace.BadParameter("invalid AWS region %q", region)
+	}
+	return nil
+}
+
+// CheckRoleARN ensures arn represents an IAM Role ARN.
+//
+// An ARN has the following format:
+// arn:partition:service:region:account-id:resource-type/resource-id
+//
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_identifiers.html#identifiers-arns
+func CheckRoleARN(arn string) error {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 {
+		return trace.BadParameter("invalid AWS ARN %q: expected 6 colon-separated sections", arn)
+	}
+	if parts[0] != "arn" || parts[2] != "iam" {
+		return trace.BadParameter("invalid AWS ARN %q: expected an arn:<partition>:iam ARN", arn)
+	}
+
+	resourceType, resourceName, ok := strings.Cut(parts[5], "/")
+	if !ok || resourceType != "role" || resourceName == "" {
+		return trace.BadParameter("invalid AWS ARN %q: expected a role resource, got %q", arn, parts[5])
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	samllib "github.com/crewjam/saml"
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+)
+
+// metadataFetchTimeout bounds how long we wait for an IdP to serve its
+// metadata document.
+const metadataFetchTimeout = 10 * time.Second
+
+// HydrateFromMetadata populates a connector's SSOURL/IssuerURL/Cert from its
+// EntityDescriptor (or EntityDescriptorURL) so that operators can configure
+// SAML from IdP-exported metadata instead of hand-filling those fields. It
+// is a no-op if neither is set, so hand-configured connectors keep working.
+func HydrateFromMetadata(ctx context.Context, connector types.SAMLConnector) error {
+	raw := connector.GetEntityDescriptor()
+	if raw == "" && connector.GetEntityDescriptorURL() != "" {
+		fetched, err := fetchMetadata(ctx, connector.GetEntityDescriptorURL())
+		if err != nil {
+			return trace.Wrap(err, "fetching SAML IdP metadata")
+		}
+		raw = fetched
+		connector.SetEntityDescriptor(raw)
+	}
+	if raw == "" {
+		return nil
+	}
+
+	var descriptor samllib.EntityDescriptor
+	if err := xml.Unmarshal([]byte(raw), &descriptor); err != nil {
+		return trace.BadParameter("parsing SAML IdP metadata: %v", err)
+	}
+
+	var pemCerts []string
+	for _, idp := range descriptor.IDPSSODescriptors {
+		for _, sso := range idp.SingleSignOnServices {
+			if sso.Binding == samllib.HTTPRedirectBinding || sso.Binding == samllib.HTTPPostBinding {
+				connector.SetSSO(sso.Location)
+				break
+			}
+		}
+		for _, kd := range idp.KeyDescriptors {
+			if kd.Use != "" && kd.Use != "signing" {
+				continue
+			}
+			for _, cert := range kd.KeyInfo.X509Data.X509Certificates {
+				pemCert, err := pemEncodeCert(cert.Data)
+				if err != nil {
+					return trace.Wrap(err, "decoding SAML IdP signing certificate")
+				}
+				pemCerts = append(pemCerts, pemCert)
+			}
+		}
+	}
+	// IdPs commonly publish more than one signing cert at once during a
+	// key rollover (old + new); concatenate every PEM block we found into
+	// a single chain instead of keeping only the last one, so
+	// parseCertChain can still validate assertions signed by either.
+	if len(pemCerts) > 0 {
+		connector.SetCert(strings.Join(pemCerts, ""))
+	}
+	if descriptor.EntityID != "" {
+		connector.SetIssuer(descriptor.EntityID)
+	}
+
+	return nil
+}
+
+// pemEncodeCert decodes a base64 DER certificate (as embedded in IdP
+// metadata) and wraps the raw DER bytes in PEM armor so it matches the
+// format the rest of the SAML stack expects from connector.GetCert().
+func pemEncodeCert(base64DER string) (string, error) {
+	der, err := base64.StdEncoding.DecodeString(base64DER)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	})), nil
+}
+
+func fetchMetadata(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, metadataFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", trace.BadParameter("fetching metadata from %q returned status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	return string(body), nil
+}
+
+// GenerateServiceProviderMetadata returns an SP EntityDescriptor XML
+// document describing the ACS URL, SLO URL and requested NameID formats for
+// the given connector, plus its encryption cert when one is configured,
+// suitable for upload to an IdP like Okta or ADFS. The document itself is
+// not signed.
+func GenerateServiceProviderMetadata(connector types.SAMLConnector) ([]byte, error) {
+	sp := &samllib.ServiceProvider{
+		EntityID: connector.GetServiceProviderIssuer(),
+	}
+	if acsURL := connector.GetAssertionConsumerService(); acsURL != "" {
+		if err := sp.AcsURL.UnmarshalText([]byte(acsURL)); err != nil {
+			return nil, trace.Wrap(err, "parsing assertion consumer service URL")
+		}
+	}
+	if sloURL := connector.GetSLOURL(); sloURL != "" {
+		if err := sp.SloURL.UnmarshalText([]byte(sloURL)); err != nil {
+			return nil, trace.Wrap(err, "parsing single logout URL")
+		}
+	}
+	if cert, err := encryptionCertificate(connector); err != nil {
+		return nil, trace.Wrap(err)
+	} else if cert != nil {
+		sp.Certificate = cert
+	}
+
+	descriptor := sp.Metadata()
+	out, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, trace.Wrap(err, "marshaling SP metadata")
+	}
+
+	return out, nil
+}
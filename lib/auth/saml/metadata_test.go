@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// selfSignedCertDER returns the DER bytes of a throwaway self-signed
+// certificate, as would be embedded (base64-encoded) in a real IdP's
+// metadata document.
+func selfSignedCertDER(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return der
+}
+
+const metadataTemplate = `<?xml version="1.0"?>
+<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <KeyDescriptor use="signing">
+      <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#">
+        <X509Data>
+          <X509Certificate>%s</X509Certificate>
+        </X509Data>
+      </KeyInfo>
+    </KeyDescriptor>
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`
+
+const metadataTemplateTwoCerts = `<?xml version="1.0"?>
+<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <KeyDescriptor use="signing">
+      <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#">
+        <X509Data>
+          <X509Certificate>%s</X509Certificate>
+        </X509Data>
+      </KeyInfo>
+    </KeyDescriptor>
+    <KeyDescriptor use="signing">
+      <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#">
+        <X509Data>
+          <X509Certificate>%s</X509Certificate>
+        </X509Data>
+      </KeyInfo>
+    </KeyDescriptor>
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`
+
+// TestHydrateFromMetadataKeepsEveryRolloverCert is the regression test for
+// the chunk0-4 follow-up: an IdP publishing two signing certs at once (the
+// normal shape of a key rollover, old cert + new cert) must end up with
+// both concatenated into connector.GetCert(), not just the last one seen.
+func TestHydrateFromMetadataKeepsEveryRolloverCert(t *testing.T) {
+	oldDER := selfSignedCertDER(t)
+	newDER := selfSignedCertDER(t)
+	metadata := fmt.Sprintf(metadataTemplateTwoCerts,
+		base64.StdEncoding.EncodeToString(oldDER),
+		base64.StdEncoding.EncodeToString(newDER))
+
+	connector := &stubConnector{entityDescriptor: metadata}
+	require.NoError(t, HydrateFromMetadata(context.Background(), connector))
+
+	certs, err := parseCertChain(connector.GetCert())
+	require.NoError(t, err, "PEM chain produced by HydrateFromMetadata must be parseable")
+	require.Len(t, certs, 2, "both rollover certs must be kept, not just the last one")
+}
+
+// TestHydrateFromMetadataRoundTrip is the regression test for chunk0-4: it
+// would have caught pemEncodeCert PEM-wrapping the base64 certificate text
+// directly instead of decoding it first, which produced a cert that
+// parseCertChain (and any real x509 consumer) could never parse.
+func TestHydrateFromMetadataRoundTrip(t *testing.T) {
+	der := selfSignedCertDER(t)
+	metadata := fmt.Sprintf(metadataTemplate, base64.StdEncoding.EncodeToString(der))
+
+	connector := &stubConnector{entityDescriptor: metadata}
+	require.NoError(t, HydrateFromMetadata(context.Background(), connector))
+
+	require.Equal(t, "https://idp.example.com/sso", connector.GetSSO())
+	require.Equal(t, "https://idp.example.com/metadata", connector.issuer)
+
+	certs, err := parseCertChain(connector.GetCert())
+	require.NoError(t, err, "PEM cert produced by HydrateFromMetadata must be parseable")
+	require.Len(t, certs, 1)
+	require.Equal(t, "test-idp", certs[0].Subject.CommonName)
+}
+
+func TestPemEncodeCertRoundTrip(t *testing.T) {
+	der := selfSignedCertDER(t)
+
+	pemCert, err := pemEncodeCert(base64.StdEncoding.EncodeToString(der))
+	require.NoError(t, err)
+
+	certs, err := parseCertChain(pemCert)
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	require.Equal(t, "test-idp", certs[0].Subject.CommonName)
+}
+
+func TestPemEncodeCertRejectsInvalidBase64(t *testing.T) {
+	_, err := pemEncodeCert("not-valid-base64!!!")
+	require.Error(t, err)
+}
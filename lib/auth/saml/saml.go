@@ -0,0 +1,365 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package saml provides a pure-Go, in-tree implementation of auth.SAMLService
+// so that SAML authentication works in the OSS build without requiring the
+// Enterprise plugin. It is built on crewjam/saml, which wraps the
+// etree/goxmldsig stack for XML signature verification.
+package saml
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gravitational/teleport/api/types"
+	apievents "github.com/gravitational/teleport/api/types/events"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/events"
+)
+
+// requestTTL bounds how long a generated AuthnRequest ID remains valid for a
+// matching InResponseTo. It intentionally tracks the typical IdP assertion
+// validity window rather than the (unknown at request time) NotOnOrAfter of
+// whatever response eventually comes back.
+const requestTTL = 15 * time.Minute
+
+// ConnectorGetter looks up SAML connectors by name, as implemented by
+// lib/services.Identity.
+type ConnectorGetter interface {
+	GetSAMLConnector(ctx context.Context, id string, withSecrets bool) (types.SAMLConnector, error)
+}
+
+// RequestStore persists outstanding AuthnRequest IDs so responses can be
+// matched against InResponseTo and consumed exactly once.
+type RequestStore interface {
+	// CreateSAMLAuthRequest stores req, expiring it after ttl.
+	CreateSAMLAuthRequest(ctx context.Context, req types.SAMLAuthRequest, ttl time.Duration) error
+	// ConsumeSAMLAuthRequest atomically fetches and deletes the stored
+	// request with the given ID, returning trace.NotFound if it is missing,
+	// already consumed, or expired.
+	ConsumeSAMLAuthRequest(ctx context.Context, id string) (*types.SAMLAuthRequest, error)
+	// CreateSAMLLogoutRequest stores req, expiring it after ttl.
+	CreateSAMLLogoutRequest(ctx context.Context, req types.SAMLLogoutRequest, ttl time.Duration) error
+	// ConsumeSAMLLogoutRequest atomically fetches and deletes the stored
+	// logout request with the given ID.
+	ConsumeSAMLLogoutRequest(ctx context.Context, id string) (*types.SAMLLogoutRequest, error)
+}
+
+// Config configures the default Service.
+type Config struct {
+	// Connectors is used to fetch the connector referenced by a given auth or
+	// validation request.
+	Connectors ConnectorGetter
+	// Requests tracks outstanding AuthnRequest IDs for InResponseTo
+	// validation and single-use enforcement.
+	Requests RequestStore
+	// Sessions provides access to web sessions for Single Logout. Optional:
+	// CreateSAMLLogoutRequest/ValidateSAMLLogoutResponse return an error if
+	// SLO is attempted without it configured.
+	Sessions SessionStore
+	// KeyStore decrypts <EncryptedAssertion> elements using a connector's
+	// configured SP key. Optional: only required for connectors with
+	// encryption configured.
+	KeyStore KeyStore
+	// Emitter is used to record SAMLResponseReplayed audit events.
+	Emitter events.Emitter
+	// Clock is used to check assertion validity windows. Defaults to the
+	// real clock.
+	Clock interface{ Now() time.Time }
+}
+
+func (c *Config) CheckAndSetDefaults() error {
+	if c.Connectors == nil {
+		return trace.BadParameter("missing required parameter Connectors for saml.Service")
+	}
+	if c.Requests == nil {
+		return trace.BadParameter("missing required parameter Requests for saml.Service")
+	}
+	if c.Emitter == nil {
+		return trace.BadParameter("missing required parameter Emitter for saml.Service")
+	}
+	if c.Clock == nil {
+		c.Clock = realClock{}
+	}
+	return nil
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Service is the default, Enterprise-free implementation of
+// auth.SAMLService. It builds AuthnRequests, verifies IdP responses against
+// the connector's certificate chain, and maps the resulting attribute
+// statements onto a types.ExternalIdentity.
+type Service struct {
+	cfg Config
+}
+
+// NewService returns a Service satisfying auth.SAMLService.
+func NewService(cfg Config) (*Service, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Service{cfg: cfg}, nil
+}
+
+// CreateSAMLAuthRequest builds a SAML AuthnRequest for the connector named in
+// req.ConnectorID, persists its ID for InResponseTo matching, and returns
+// req with ID set to the AuthnRequest's ID. It does not set a redirect URL
+// or an HTTP-POST form for the request.
+func (s *Service) CreateSAMLAuthRequest(ctx context.Context, req types.SAMLAuthRequest) (*types.SAMLAuthRequest, error) {
+	connector, err := s.cfg.Connectors.GetSAMLConnector(ctx, req.ConnectorID, false)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	sp, err := s.serviceProvider(connector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	authReq, err := sp.MakeAuthenticationRequest(connector.GetSSO(), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, trace.Wrap(err, "building SAML AuthnRequest")
+	}
+
+	req.ID = authReq.ID
+
+	if err := s.cfg.Requests.CreateSAMLAuthRequest(ctx, req, requestTTL); err != nil {
+		return nil, trace.Wrap(err, "persisting SAML AuthnRequest")
+	}
+
+	return &req, nil
+}
+
+// ValidateSAMLResponse parses and verifies a SAML response and maps its
+// attribute statements into a SAMLAuthResponse.
+//
+// Unless the connector has AllowIDPInitiated set, the response's
+// InResponseTo must match a request previously created by
+// CreateSAMLAuthRequest for this same connector; that request is consumed
+// (deleted) as part of validation so the same ID can never be replayed.
+func (s *Service) ValidateSAMLResponse(ctx context.Context, re string, connectorID string) (*auth.SAMLAuthResponse, error) {
+	connector, err := s.cfg.Connectors.GetSAMLConnector(ctx, connectorID, true)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var possibleRequestIDs []string
+	if !connector.GetAllowIDPInitiated() {
+		inResponseTo, err := peekInResponseTo(re)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if inResponseTo == "" {
+			s.emitReplayed(ctx, connectorID, "")
+			return nil, trace.AccessDenied("IdP-initiated SAML responses are not allowed for this connector")
+		}
+
+		storedReq, err := s.cfg.Requests.ConsumeSAMLAuthRequest(ctx, inResponseTo)
+		if err != nil || storedReq.ConnectorID != connectorID {
+			s.emitReplayed(ctx, connectorID, inResponseTo)
+			return nil, trace.AccessDenied("SAML response does not match an outstanding request")
+		}
+
+		possibleRequestIDs = []string{inResponseTo}
+	}
+
+	re, err = s.decryptIfNeeded(ctx, connector, re)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	sp, err := s.serviceProvider(connector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	httpReq, err := newPostBindingRequest(sp.AcsURL.String(), re)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	assertion, err := sp.ParseResponse(httpReq, possibleRequestIDs)
+	if err != nil {
+		return nil, trace.AccessDenied("SAML response validation failed: %v", err)
+	}
+
+	identity, err := mapAssertionToIdentity(assertion)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if mapping := connector.GetAttributeMapping(); len(mapping) > 0 {
+		traits, err := applyAttributeMapping(mapping, attributesFromAssertion(assertion))
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		identity.Traits = traits
+	}
+
+	resp := &auth.SAMLAuthResponse{
+		Identity: identity,
+	}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		resp.SAMLNameID = assertion.Subject.NameID.Value
+		resp.SAMLNameIDFormat = assertion.Subject.NameID.Format
+	}
+	if idx := sessionIndex(assertion); idx != "" {
+		resp.SAMLSessionIndex = idx
+	}
+
+	return resp, nil
+}
+
+// sessionIndex pulls the SessionIndex out of the assertion's first
+// AuthnStatement, if any.
+func sessionIndex(assertion *saml.Assertion) string {
+	for _, stmt := range assertion.AuthnStatements {
+		if stmt.SessionIndex != "" {
+			return stmt.SessionIndex
+		}
+	}
+	return ""
+}
+
+// emitReplayed records a SAMLResponseReplayed audit event, best-effort.
+func (s *Service) emitReplayed(ctx context.Context, connectorID, requestID string) {
+	if err := s.cfg.Emitter.EmitAuditEvent(ctx, &apievents.SAMLResponseReplayed{
+		Metadata: apievents.Metadata{
+			Type: events.SAMLResponseReplayedEvent,
+			Code: events.SAMLResponseReplayedCode,
+		},
+		ConnectorID: connectorID,
+		RequestID:   requestID,
+	}); err != nil {
+		log.WithError(err).Warn("Failed to emit SAML response replayed event.")
+	}
+}
+
+// serviceProvider builds a crewjam/saml.ServiceProvider configured with the
+// connector's IdP metadata and certificate chain.
+func (s *Service) serviceProvider(connector types.SAMLConnector) (*saml.ServiceProvider, error) {
+	certs, err := parseCertChain(connector.GetCert())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	acsURL, err := url.Parse(connector.GetAssertionConsumerService())
+	if err != nil {
+		return nil, trace.Wrap(err, "parsing assertion consumer service URL")
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:            connector.GetServiceProviderIssuer(),
+		AcsURL:              *acsURL,
+		IDPCertificateStore: &saml.CertificateStore{Certificates: certs},
+	}
+	if connector.GetAudience() != "" {
+		sp.AudienceURI = connector.GetAudience()
+	}
+
+	return sp, nil
+}
+
+// parseCertChain parses one or more concatenated PEM-encoded certificates,
+// allowing an IdP to rotate signing certs without downtime.
+func parseCertChain(pemChain string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(pemChain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, trace.Wrap(err, "parsing IdP certificate")
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, trace.BadParameter("connector has no usable IdP certificates")
+	}
+	return certs, nil
+}
+
+// mapAssertionToIdentity extracts the authenticated Subject NameID from
+// assertion into a types.ExternalIdentity. It does not resolve Teleport
+// roles: legacy AttributesToRoles matching is done by the user-provisioning
+// flow that consumes SAMLAuthResponse, and AttributeMapping-based trait
+// derivation is handled separately by applyAttributeMapping.
+func mapAssertionToIdentity(assertion *saml.Assertion) (types.ExternalIdentity, error) {
+	identity := types.ExternalIdentity{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		identity.Username = assertion.Subject.NameID.Value
+	}
+	if identity.Username == "" {
+		return identity, trace.BadParameter("SAML assertion has no Subject NameID")
+	}
+
+	log.Debugf("Mapped SAML assertion for %q from %d attribute statements.", identity.Username, len(assertion.AttributeStatements))
+	return identity, nil
+}
+
+// responseEnvelope captures just enough of the top-level <samlp:Response/>
+// to recover InResponseTo before full signature verification happens, so
+// that we know which outstanding request to consume.
+type responseEnvelope struct {
+	InResponseTo string `xml:"InResponseTo,attr"`
+}
+
+// peekInResponseTo extracts InResponseTo from a base64-encoded SAML
+// response without verifying its signature. The value is untrusted until
+// ParseResponse succeeds, but it is only ever used as a lookup key into the
+// request store, which is itself the security boundary.
+func peekInResponseTo(re string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(re)
+	if err != nil {
+		return "", trace.BadParameter("invalid SAML response encoding: %v", err)
+	}
+
+	var envelope responseEnvelope
+	if err := xml.Unmarshal(raw, &envelope); err != nil {
+		return "", trace.BadParameter("invalid SAML response XML: %v", err)
+	}
+
+	return envelope.InResponseTo, nil
+}
+
+// newPostBindingRequest wraps a raw "SAMLResponse" form value in the
+// *http.Request shape that crewjam/saml's ServiceProvider.ParseResponse
+// expects for the HTTP-POST binding.
+func newPostBindingRequest(acsURL, samlResponse string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, acsURL, nil)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	req.PostForm = url.Values{"SAMLResponse": []string{samlResponse}}
+	return req, nil
+}
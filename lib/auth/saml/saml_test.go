@@ -0,0 +1,224 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+	apievents "github.com/gravitational/teleport/api/types/events"
+	"github.com/gravitational/teleport/lib/events"
+)
+
+// stubConnector is a minimal types.SAMLConnector for tests: it embeds the
+// interface so it satisfies it, and overrides only the getters/setters the
+// code under test actually calls. Calling an unoverridden method will panic
+// on the nil embedded interface, which is the point: it fails loudly if a
+// test starts exercising a path it didn't account for.
+type stubConnector struct {
+	types.SAMLConnector
+
+	name                      string
+	allowIDPInitiated         bool
+	requireEncryptedAssertion bool
+	cert                      string
+	entityDescriptor          string
+	entityDescriptorURL       string
+	sso                       string
+	issuer                    string
+	audience                  string
+	assertionConsumerService  string
+	serviceProviderIssuer     string
+	attributeMapping          []types.SAMLAttributeMapping
+}
+
+func (c *stubConnector) GetName() string                     { return c.name }
+func (c *stubConnector) GetAllowIDPInitiated() bool          { return c.allowIDPInitiated }
+func (c *stubConnector) GetRequireEncryptedAssertions() bool { return c.requireEncryptedAssertion }
+func (c *stubConnector) GetCert() string                     { return c.cert }
+func (c *stubConnector) GetEntityDescriptor() string         { return c.entityDescriptor }
+func (c *stubConnector) GetEntityDescriptorURL() string      { return c.entityDescriptorURL }
+func (c *stubConnector) GetSSO() string                      { return c.sso }
+func (c *stubConnector) GetAudience() string                 { return c.audience }
+func (c *stubConnector) GetAssertionConsumerService() string { return c.assertionConsumerService }
+func (c *stubConnector) GetServiceProviderIssuer() string    { return c.serviceProviderIssuer }
+func (c *stubConnector) GetAttributeMapping() []types.SAMLAttributeMapping {
+	return c.attributeMapping
+}
+
+func (c *stubConnector) SetEntityDescriptor(v string) { c.entityDescriptor = v }
+func (c *stubConnector) SetSSO(v string)              { c.sso = v }
+func (c *stubConnector) SetCert(v string)             { c.cert = v }
+func (c *stubConnector) SetIssuer(v string)           { c.issuer = v }
+
+// stubConnectorGetter implements ConnectorGetter over a single fixed
+// connector, regardless of the requested ID.
+type stubConnectorGetter struct {
+	connector types.SAMLConnector
+}
+
+func (g stubConnectorGetter) GetSAMLConnector(ctx context.Context, id string, withSecrets bool) (types.SAMLConnector, error) {
+	return g.connector, nil
+}
+
+// fakeRequestStore is an in-memory RequestStore that actually enforces
+// single-use semantics, unlike a mock that merely records calls: consuming
+// an ID deletes it, so a second consume of the same ID observably fails.
+type fakeRequestStore struct {
+	mu       sync.Mutex
+	requests map[string]types.SAMLAuthRequest
+}
+
+func newFakeRequestStore() *fakeRequestStore {
+	return &fakeRequestStore{requests: make(map[string]types.SAMLAuthRequest)}
+}
+
+func (s *fakeRequestStore) CreateSAMLAuthRequest(ctx context.Context, req types.SAMLAuthRequest, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[req.ID] = req
+	return nil
+}
+
+func (s *fakeRequestStore) ConsumeSAMLAuthRequest(ctx context.Context, id string) (*types.SAMLAuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, ok := s.requests[id]
+	if !ok {
+		return nil, trace.NotFound("SAML auth request %q not found", id)
+	}
+	delete(s.requests, id)
+	return &req, nil
+}
+
+func (s *fakeRequestStore) CreateSAMLLogoutRequest(ctx context.Context, req types.SAMLLogoutRequest, ttl time.Duration) error {
+	return trace.NotImplemented("fakeRequestStore does not support logout requests")
+}
+
+func (s *fakeRequestStore) ConsumeSAMLLogoutRequest(ctx context.Context, id string) (*types.SAMLLogoutRequest, error) {
+	return nil, trace.NotImplemented("fakeRequestStore does not support logout requests")
+}
+
+// stubEmitter records every audit event emitted through it.
+type stubEmitter struct {
+	events.Emitter
+
+	mu     sync.Mutex
+	events []apievents.AuditEvent
+}
+
+func (e *stubEmitter) EmitAuditEvent(ctx context.Context, event apievents.AuditEvent) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+	return nil
+}
+
+func (e *stubEmitter) recorded() []apievents.AuditEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]apievents.AuditEvent(nil), e.events...)
+}
+
+func newTestService(t *testing.T, connector types.SAMLConnector, requests RequestStore, emitter *stubEmitter) *Service {
+	t.Helper()
+	svc, err := NewService(Config{
+		Connectors: stubConnectorGetter{connector: connector},
+		Requests:   requests,
+		Emitter:    emitter,
+	})
+	require.NoError(t, err)
+	return svc
+}
+
+// TestValidateSAMLResponseRejectsReplayedInResponseTo exercises chunk0-2's
+// single-use enforcement: a given InResponseTo can be consumed exactly
+// once, so replaying the exact same SAML response a second time must fail
+// even if it would otherwise validate.
+func TestValidateSAMLResponseRejectsReplayedInResponseTo(t *testing.T) {
+	requests := newFakeRequestStore()
+	require.NoError(t, requests.CreateSAMLAuthRequest(context.Background(), types.SAMLAuthRequest{
+		ID:          "req-1",
+		ConnectorID: "my-connector",
+	}, time.Minute))
+
+	emitter := &stubEmitter{}
+	connector := &stubConnector{name: "my-connector"}
+	svc := newTestService(t, connector, requests, emitter)
+
+	re := base64.StdEncoding.EncodeToString([]byte(`<Response InResponseTo="req-1"></Response>`))
+
+	// The first attempt consumes the outstanding request. It's expected to
+	// fail further down the line (the stub connector has no usable
+	// certificate to verify a signature against), but that failure must not
+	// be reported as a replay.
+	_, err := svc.ValidateSAMLResponse(context.Background(), re, "my-connector")
+	require.Error(t, err)
+	require.Empty(t, emitter.recorded(), "first attempt isn't a replay and shouldn't emit one")
+
+	// Replaying the exact same response must now be rejected: the request
+	// was already consumed above and can't be matched a second time.
+	_, err = svc.ValidateSAMLResponse(context.Background(), re, "my-connector")
+	require.True(t, trace.IsAccessDenied(err), "replayed response should be rejected, got: %v", err)
+
+	recorded := emitter.recorded()
+	require.Len(t, recorded, 1)
+	replayed, ok := recorded[0].(*apievents.SAMLResponseReplayed)
+	require.True(t, ok, "expected a SAMLResponseReplayed event, got %T", recorded[0])
+	require.Equal(t, "req-1", replayed.RequestID)
+	require.Equal(t, "my-connector", replayed.ConnectorID)
+}
+
+// TestValidateSAMLResponseRejectsIDPInitiated covers the other half of
+// chunk0-2: an IdP-initiated response (no InResponseTo) is rejected unless
+// the connector explicitly opts in via AllowIDPInitiated.
+func TestValidateSAMLResponseRejectsIDPInitiated(t *testing.T) {
+	emitter := &stubEmitter{}
+	connector := &stubConnector{name: "my-connector", allowIDPInitiated: false}
+	svc := newTestService(t, connector, newFakeRequestStore(), emitter)
+
+	re := base64.StdEncoding.EncodeToString([]byte(`<Response></Response>`))
+
+	_, err := svc.ValidateSAMLResponse(context.Background(), re, "my-connector")
+	require.True(t, trace.IsAccessDenied(err), "IdP-initiated response should be rejected, got: %v", err)
+	require.Len(t, emitter.recorded(), 1)
+}
+
+func TestPeekInResponseTo(t *testing.T) {
+	re := base64.StdEncoding.EncodeToString([]byte(`<Response InResponseTo="req-42"></Response>`))
+	id, err := peekInResponseTo(re)
+	require.NoError(t, err)
+	require.Equal(t, "req-42", id)
+}
+
+func TestPeekInResponseToRejectsInvalidBase64(t *testing.T) {
+	_, err := peekInResponseTo("not-valid-base64!!!")
+	require.True(t, trace.IsBadParameter(err))
+}
+
+func TestPeekInResponseToRejectsInvalidXML(t *testing.T) {
+	re := base64.StdEncoding.EncodeToString([]byte(`not xml`))
+	_, err := peekInResponseTo(re)
+	require.True(t, trace.IsBadParameter(err))
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"context"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gravitational/teleport/api/types"
+	apievents "github.com/gravitational/teleport/api/types/events"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/events"
+)
+
+// logoutRequestTTL bounds how long a generated LogoutRequest ID remains
+// valid for a matching InResponseTo on the IdP's LogoutResponse.
+const logoutRequestTTL = 5 * time.Minute
+
+// SessionStore is the subset of the web session backend the SAML service
+// needs in order to bind a logout request to the session that created it
+// and terminate that session once the IdP confirms the logout.
+type SessionStore interface {
+	// GetWebSession fetches the session the caller wants to log out of.
+	GetWebSession(ctx context.Context, sessionID string) (types.WebSession, error)
+	// DeleteWebSession terminates a session following a successful SLO
+	// round trip.
+	DeleteWebSession(ctx context.Context, sessionID string) error
+}
+
+// CreateSAMLLogoutRequest builds a <LogoutRequest> bound to the
+// NameID/SessionIndex captured on the web session at login time and
+// records its ID for the matching LogoutResponse. The request is not
+// signed, and the returned SAMLLogoutRequest carries only its ID,
+// ConnectorID, SessionID and Binding.
+func (s *Service) CreateSAMLLogoutRequest(ctx context.Context, sessionID string) (*types.SAMLLogoutRequest, error) {
+	if s.cfg.Sessions == nil {
+		return nil, trace.BadParameter("SAML SLO is not configured: missing Sessions store")
+	}
+
+	webSession, err := s.cfg.Sessions.GetWebSession(ctx, sessionID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if webSession.GetSAMLSessionIndex() == "" {
+		return nil, trace.BadParameter("session %q was not established via SAML, nothing to log out of", sessionID)
+	}
+
+	connector, err := s.cfg.Connectors.GetSAMLConnector(ctx, webSession.GetSAMLConnectorID(), true)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if connector.GetSLOURL() == "" {
+		return nil, trace.BadParameter("connector %q does not have Single Logout configured", connector.GetName())
+	}
+
+	sp, err := s.serviceProvider(connector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	binding := saml.HTTPRedirectBinding
+	if connector.GetSLOBinding() == saml.HTTPPostBinding {
+		binding = saml.HTTPPostBinding
+	}
+
+	logoutReq, err := sp.MakeLogoutRequest(connector.GetSLOURL(), webSession.GetSAMLNameID())
+	if err != nil {
+		return nil, trace.Wrap(err, "building SAML LogoutRequest")
+	}
+	logoutReq.SessionIndex = webSession.GetSAMLSessionIndex()
+
+	req := &types.SAMLLogoutRequest{
+		ID:          logoutReq.ID,
+		ConnectorID: connector.GetName(),
+		SessionID:   sessionID,
+		Binding:     string(binding),
+	}
+
+	if err := s.cfg.Requests.CreateSAMLLogoutRequest(ctx, *req, logoutRequestTTL); err != nil {
+		return nil, trace.Wrap(err, "persisting SAML LogoutRequest")
+	}
+
+	s.emitLogoutCreated(ctx, connector.GetName(), sessionID, req.ID)
+
+	return req, nil
+}
+
+// ValidateSAMLLogoutResponse validates the IdP's <LogoutResponse> and, on
+// success, terminates the web session the original LogoutRequest was bound
+// to.
+func (s *Service) ValidateSAMLLogoutResponse(ctx context.Context, re string, connectorID string) error {
+	if s.cfg.Sessions == nil {
+		return trace.BadParameter("SAML SLO is not configured: missing Sessions store")
+	}
+
+	connector, err := s.cfg.Connectors.GetSAMLConnector(ctx, connectorID, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	inResponseTo, err := peekInResponseTo(re)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	storedReq, err := s.cfg.Requests.ConsumeSAMLLogoutRequest(ctx, inResponseTo)
+	if err != nil || storedReq.ConnectorID != connectorID {
+		return trace.AccessDenied("SAML logout response does not match an outstanding request")
+	}
+
+	sp, err := s.serviceProvider(connector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := sp.ValidateLogoutResponseForm(re); err != nil {
+		return trace.AccessDenied("SAML logout response validation failed: %v", err)
+	}
+
+	if err := s.cfg.Sessions.DeleteWebSession(ctx, storedReq.SessionID); err != nil {
+		return trace.Wrap(err)
+	}
+
+	s.emitLogoutCompleted(ctx, connectorID, storedReq.SessionID)
+	return nil
+}
+
+func (s *Service) emitLogoutCreated(ctx context.Context, connectorID, sessionID, requestID string) {
+	if err := s.cfg.Emitter.EmitAuditEvent(ctx, &apievents.SAMLLogoutRequestCreated{
+		Metadata: apievents.Metadata{
+			Type: events.SAMLLogoutRequestCreatedEvent,
+			Code: events.SAMLLogoutRequestCreatedCode,
+		},
+		ConnectorID: connectorID,
+		SessionID:   sessionID,
+		RequestID:   requestID,
+	}); err != nil {
+		log.WithError(err).Warn("Failed to emit SAML logout request created event.")
+	}
+}
+
+func (s *Service) emitLogoutCompleted(ctx context.Context, connectorID, sessionID string) {
+	if err := s.cfg.Emitter.EmitAuditEvent(ctx, &apievents.SAMLLogoutCompleted{
+		Metadata: apievents.Metadata{
+			Type: events.SAMLLogoutCompletedEvent,
+			Code: events.SAMLLogoutCompletedCode,
+		},
+		ConnectorID: connectorID,
+		SessionID:   sessionID,
+	}); err != nil {
+		log.WithError(err).Warn("Failed to emit SAML logout completed event.")
+	}
+}
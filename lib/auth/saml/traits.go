@@ -0,0 +1,113 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package saml
+
+import (
+	"regexp"
+	"strings"
+
+	samllib "github.com/crewjam/saml"
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+)
+
+// regexReplacePattern matches calls like regex_replace(user.department,
+// "^eng-", ""), the one expression form this chunk supports beyond plain
+// attribute/array references.
+var regexReplacePattern = regexp.MustCompile(`^regex_replace\(\s*([\w.]+)\s*,\s*"((?:[^"\\]|\\.)*)"\s*,\s*"((?:[^"\\]|\\.)*)"\s*\)$`)
+
+// rawAttributes is a friendlier view over an assertion's attribute
+// statements: attribute name -> all of its values.
+type rawAttributes map[string][]string
+
+// attributesFromAssertion flattens every AttributeStatement in the
+// assertion into a single name -> values map, as role templates only see
+// the union regardless of which statement an attribute came from.
+func attributesFromAssertion(assertion *samllib.Assertion) rawAttributes {
+	out := rawAttributes{}
+	for _, stmt := range assertion.AttributeStatements {
+		for _, attr := range stmt.Attributes {
+			for _, v := range attr.Values {
+				out[attr.Name] = append(out[attr.Name], v.Value)
+			}
+		}
+	}
+	return out
+}
+
+// applyAttributeMapping evaluates each entry of mapping against attrs and
+// returns the resulting traits, falling back to mapping's configured
+// default when the source attribute is absent.
+func applyAttributeMapping(mapping []types.SAMLAttributeMapping, attrs rawAttributes) (map[string][]string, error) {
+	traits := make(map[string][]string, len(mapping))
+
+	for _, m := range mapping {
+		values, err := evalAttributeExpression(m.Expression, attrs)
+		if err != nil {
+			return nil, trace.Wrap(err, "evaluating attribute mapping for trait %q", m.Trait)
+		}
+		if len(values) == 0 && m.Default != "" {
+			values = []string{m.Default}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		traits[m.Trait] = values
+	}
+
+	return traits, nil
+}
+
+// evalAttributeExpression supports a small, fixed set of forms rather than
+// a general expression language:
+//
+//	email             -> the single value of attribute "email"
+//	groups[*]         -> every value of attribute "groups"
+//	regex_replace(user.department, "^eng-", "") -> attribute "user.department"
+//	                     with the regex stripped from each value
+func evalAttributeExpression(expr string, attrs rawAttributes) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+
+	if m := regexReplacePattern.FindStringSubmatch(expr); m != nil {
+		attrName, pattern, replacement := m[1], unescapeQuotes(m[2]), unescapeQuotes(m[3])
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, trace.BadParameter("invalid regex_replace pattern %q: %v", pattern, err)
+		}
+		values := attrs[attrName]
+		out := make([]string, len(values))
+		for i, v := range values {
+			out[i] = re.ReplaceAllString(v, replacement)
+		}
+		return out, nil
+	}
+
+	if name, ok := strings.CutSuffix(expr, "[*]"); ok {
+		return attrs[name], nil
+	}
+
+	values := attrs[expr]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values[:1], nil
+}
+
+func unescapeQuotes(s string) string {
+	return strings.ReplaceAll(s, `\"`, `"`)
+}
@@ -0,0 +1,837 @@
+/*
+Copyright 2023 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/services/local/accesslist/audit"
+)
+
+const (
+	accessListPrefix            = "access_list"
+	accessListMemberPrefix      = "access_list_member"
+	accessListReviewPrefix      = "access_list_review"
+	accessListReviewDiffPrefix  = "access_list_review_diff"
+	accessListOwnerIndexPrefix  = "access_list_owner_index"
+	accessListMaxPageSize       = 200
+	accessListDefaultMaxPageLen = 100
+
+	// maxTransitiveDepth caps how deep GetAccessListMembersTransitive will
+	// descend through nested access lists, guarding against pathologically
+	// deep (if acyclic) nesting.
+	maxTransitiveDepth = 10
+)
+
+// AccessListService manages AccessList resources in the backend.
+type AccessListService struct {
+	service                   *genericService[*accesslist.AccessList]
+	members                   *genericService[*accesslist.AccessListMember]
+	reviews                   *genericService[*accesslist.Review]
+	clock                     clockwork.Clock
+	validators                []MembershipValidator
+	reviewExpirationEvaluator *ReviewExpirationEvaluator
+}
+
+// AccessListServiceOption configures optional behavior of
+// AccessListService at construction time.
+type AccessListServiceOption func(*AccessListService)
+
+// WithMembershipValidators registers one or more MembershipValidators
+// that must approve a candidate member before UpsertAccessListMember or
+// UpsertAccessListWithMembers will write it. Validators run in the order
+// given; all of them run even after one rejects the member, and their
+// errors are aggregated via trace.NewAggregate. A validator returning
+// trace.ConnectionProblem stops the run, and that error is returned alone.
+func WithMembershipValidators(v ...MembershipValidator) AccessListServiceOption {
+	return func(a *AccessListService) {
+		a.validators = append(a.validators, v...)
+	}
+}
+
+// WithReviewExpirationEvaluator overrides the default-windowed
+// ReviewExpirationEvaluator used by EvaluateReviewStatus and
+// ReviewSweeper.
+func WithReviewExpirationEvaluator(e *ReviewExpirationEvaluator) AccessListServiceOption {
+	return func(a *AccessListService) {
+		a.reviewExpirationEvaluator = e
+	}
+}
+
+// NewAccessListService creates a new AccessListService.
+func NewAccessListService(b backend.Backend, clock clockwork.Clock, opts ...AccessListServiceOption) (*AccessListService, error) {
+	service, err := newGenericService(b, accessListPrefix, services.MarshalAccessList, services.UnmarshalAccessList)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	members, err := newGenericService(b, accessListMemberPrefix, services.MarshalAccessListMember, services.UnmarshalAccessListMember)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	reviews, err := newGenericService(b, accessListReviewPrefix, services.MarshalAccessListReview, services.UnmarshalAccessListReview)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	a := &AccessListService{
+		service: service,
+		members: members,
+		reviews: reviews,
+		clock:   clock,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a, nil
+}
+
+// GetAccessLists returns all access lists.
+func (a *AccessListService) GetAccessLists(ctx context.Context) ([]*accesslist.AccessList, error) {
+	lists, err := a.service.getAll(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, list := range lists {
+		dedupeOwners(list)
+	}
+	return lists, nil
+}
+
+// ListAccessLists lists a page of access lists.
+func (a *AccessListService) ListAccessLists(ctx context.Context, pageSize int, nextToken string) ([]*accesslist.AccessList, string, error) {
+	lists, next, err := a.service.list(ctx, pageSize, nextToken)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	for _, list := range lists {
+		dedupeOwners(list)
+	}
+	return lists, next, nil
+}
+
+// GetAccessList returns an access list by name.
+func (a *AccessListService) GetAccessList(ctx context.Context, name string) (*accesslist.AccessList, error) {
+	list, err := a.service.get(ctx, name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	dedupeOwners(list)
+	return list, nil
+}
+
+// UpsertAccessList creates or updates an access list.
+func (a *AccessListService) UpsertAccessList(ctx context.Context, list *accesslist.AccessList) (*accesslist.AccessList, error) {
+	if err := validateUniqueOwners(list); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	prevOwners, _ := a.ownerNames(ctx, list.GetName())
+
+	if err := a.service.upsert(ctx, list); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if err := a.updateOwnerIndex(ctx, list.GetName(), prevOwners, ownerNames(list)); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return list, nil
+}
+
+// ConditionalUpdateAccessList updates list only if its current backend
+// revision still matches expectedRevision, returning trace.CompareFailed
+// if a concurrent writer already changed it.
+func (a *AccessListService) ConditionalUpdateAccessList(ctx context.Context, list *accesslist.AccessList, expectedRevision string) (*accesslist.AccessList, error) {
+	if err := validateUniqueOwners(list); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	prevOwners, _ := a.ownerNames(ctx, list.GetName())
+
+	if err := a.service.conditionalUpdate(ctx, list.GetName(), list, expectedRevision); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if err := a.updateOwnerIndex(ctx, list.GetName(), prevOwners, ownerNames(list)); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return list, nil
+}
+
+// ConditionalDeleteAccessList deletes name only if its current backend
+// revision still matches expectedRevision.
+func (a *AccessListService) ConditionalDeleteAccessList(ctx context.Context, name, expectedRevision string) error {
+	prevOwners, _ := a.ownerNames(ctx, name)
+
+	if err := a.service.conditionalDelete(ctx, name, expectedRevision); err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := a.members.deleteAllWithPrefix(ctx, name); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.reviews.deleteAllWithPrefix(ctx, name); err != nil {
+		return trace.Wrap(err)
+	}
+
+	return trace.Wrap(a.updateOwnerIndex(ctx, name, prevOwners, nil))
+}
+
+// DeleteAccessList deletes an access list along with its members and
+// reviews.
+func (a *AccessListService) DeleteAccessList(ctx context.Context, name string) error {
+	prevOwners, _ := a.ownerNames(ctx, name)
+
+	if err := a.service.delete(ctx, name); err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := a.members.deleteAllWithPrefix(ctx, name); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.reviews.deleteAllWithPrefix(ctx, name); err != nil {
+		return trace.Wrap(err)
+	}
+
+	return trace.Wrap(a.updateOwnerIndex(ctx, name, prevOwners, nil))
+}
+
+// DeleteAllAccessLists deletes all access lists, members, and reviews.
+func (a *AccessListService) DeleteAllAccessLists(ctx context.Context) error {
+	if err := a.service.deleteAll(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.members.deleteAll(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.reviews.deleteAll(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.deleteOwnerIndex(ctx))
+}
+
+// GetAccessListMember returns an access list member.
+func (a *AccessListService) GetAccessListMember(ctx context.Context, accessList, memberName string) (*accesslist.AccessListMember, error) {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return a.members.get(ctx, memberKey(accessList, memberName))
+}
+
+// ListAccessListMembers lists a page of members for the given access list.
+func (a *AccessListService) ListAccessListMembers(ctx context.Context, accessList string, pageSize int, nextToken string) ([]*accesslist.AccessListMember, string, error) {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	return a.members.listWithPrefix(ctx, accessList, pageSize, nextToken)
+}
+
+// UpsertAccessListMember creates or updates an access list member.
+func (a *AccessListService) UpsertAccessListMember(ctx context.Context, member *accesslist.AccessListMember) (*accesslist.AccessListMember, error) {
+	list, err := a.service.get(ctx, member.Spec.AccessList)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if member.Spec.MembershipKind == accesslist.MembershipKindList {
+		if err := a.checkForCycle(ctx, member.Spec.AccessList, member.GetName(), map[string]struct{}{}); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+
+	if err := a.runValidators(ctx, list, member); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if err := a.members.upsertKeyed(ctx, memberKey(member.Spec.AccessList, member.GetName()), member); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return member, nil
+}
+
+// runValidators runs every registered MembershipValidator against member,
+// aggregating every rejection rather than stopping at the first one. A
+// trace.ConnectionProblem from any validator is returned immediately.
+func (a *AccessListService) runValidators(ctx context.Context, list *accesslist.AccessList, member *accesslist.AccessListMember) error {
+	if len(a.validators) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, v := range a.validators {
+		if err := v.Validate(ctx, list, member); err != nil {
+			if trace.IsConnectionProblem(err) {
+				// A validator that couldn't be reached at all is a
+				// different failure mode than one that was reached and
+				// said no: callers (see CreateAccessListReview) must not
+				// treat this the same as a verdict that the member is
+				// non-compliant, so don't fold it into the aggregate.
+				return trace.Wrap(err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return trace.NewAggregate(errs...)
+}
+
+// checkForCycle reports whether rootList is reachable by descending
+// through nested-list members starting at child, i.e. whether adding
+// child as a member of rootList would close a loop.
+func (a *AccessListService) checkForCycle(ctx context.Context, rootList, child string, visited map[string]struct{}) error {
+	if child == rootList {
+		return trace.BadParameter("adding access list %q as a member of %q would introduce a cycle", child, rootList)
+	}
+	if _, ok := visited[child]; ok {
+		return nil
+	}
+	visited[child] = struct{}{}
+
+	members, _, err := a.members.listWithPrefix(ctx, child, 0, "")
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	for _, m := range members {
+		if m.Spec.MembershipKind != accesslist.MembershipKindList {
+			continue
+		}
+		if err := a.checkForCycle(ctx, rootList, m.GetName(), visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConditionalUpdateAccessListMember updates member only if its current
+// backend revision still matches expectedRevision.
+func (a *AccessListService) ConditionalUpdateAccessListMember(ctx context.Context, member *accesslist.AccessListMember, expectedRevision string) (*accesslist.AccessListMember, error) {
+	list, err := a.service.get(ctx, member.Spec.AccessList)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := a.runValidators(ctx, list, member); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := a.members.conditionalUpdate(ctx, memberKey(member.Spec.AccessList, member.GetName()), member, expectedRevision); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return member, nil
+}
+
+// ConditionalDeleteAccessListMember deletes a member only if its current
+// backend revision still matches expectedRevision.
+func (a *AccessListService) ConditionalDeleteAccessListMember(ctx context.Context, accessList, memberName, expectedRevision string) error {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.members.conditionalDelete(ctx, memberKey(accessList, memberName), expectedRevision))
+}
+
+// DeleteAccessListMember deletes a member from an access list.
+func (a *AccessListService) DeleteAccessListMember(ctx context.Context, accessList, memberName string) error {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.members.deleteKeyed(ctx, memberKey(accessList, memberName)))
+}
+
+// GetAccessListMembersTransitive returns the flattened set of user
+// members of name, resolving any nested access lists (members whose
+// MembershipKind is accesslist.MembershipKindList) via a DFS over the
+// membership graph. Duplicate users reachable through more than one path
+// (diamond inheritance) are returned once.
+//
+// This method resolves structure only: a user returned here was validated
+// against the MembershipRequires of the list they were directly added to,
+// not against the MembershipRequires of name or of any intermediate list
+// on the path down to them. A user who satisfies a nested list's
+// requirements but not an ancestor's is still included.
+//
+// Cycles are rejected at write time by UpsertAccessListMember, but a
+// cycle is still detected defensively here in case the graph was
+// populated out-of-band (e.g. restored from a backup).
+func (a *AccessListService) GetAccessListMembersTransitive(ctx context.Context, name string) ([]*accesslist.AccessListMember, error) {
+	if _, err := a.service.get(ctx, name); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	path := map[string]struct{}{name: {}}
+	resolved := make(map[string]struct{})
+	seenUsers := make(map[string]*accesslist.AccessListMember)
+	if err := a.collectTransitiveMembers(ctx, name, 0, path, resolved, seenUsers); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	out := make([]*accesslist.AccessListMember, 0, len(seenUsers))
+	for _, m := range seenUsers {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+
+	return out, nil
+}
+
+// collectTransitiveMembers walks the nested-list members of listName,
+// recording every user member reached into seenUsers. path tracks the
+// lists currently on the stack from the root down to listName (to detect
+// a genuine cycle -- the same list reached twice on one path); it is
+// popped on return so a list revisited via a different branch (diamond
+// inheritance) isn't mistaken for one revisited via the same branch.
+// resolved instead tracks lists that have already been fully walked at
+// any point, so a diamond's shared descendant is only walked once.
+func (a *AccessListService) collectTransitiveMembers(ctx context.Context, listName string, depth int, path, resolved map[string]struct{}, seenUsers map[string]*accesslist.AccessListMember) error {
+	if depth > maxTransitiveDepth {
+		return trace.BadParameter("access list %q nesting exceeds max depth of %d", listName, maxTransitiveDepth)
+	}
+	if _, ok := resolved[listName]; ok {
+		return nil
+	}
+
+	members, _, err := a.members.listWithPrefix(ctx, listName, 0, "")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	for _, m := range members {
+		if m.Spec.MembershipKind != accesslist.MembershipKindList {
+			if _, ok := seenUsers[m.GetName()]; !ok {
+				seenUsers[m.GetName()] = m
+			}
+			continue
+		}
+
+		child := m.GetName()
+		if _, ok := path[child]; ok {
+			return trace.BadParameter("cycle detected: access list %q is nested within itself via %q", child, listName)
+		}
+		path[child] = struct{}{}
+		err := a.collectTransitiveMembers(ctx, child, depth+1, path, resolved, seenUsers)
+		delete(path, child)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
+	resolved[listName] = struct{}{}
+	return nil
+}
+
+// DeleteAllAccessListMembersForAccessList removes every member of the given
+// access list.
+func (a *AccessListService) DeleteAllAccessListMembersForAccessList(ctx context.Context, accessList string) error {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.members.deleteAllWithPrefix(ctx, accessList))
+}
+
+// UpsertAccessListWithMembers upserts an access list and replaces its
+// complete set of members atomically from Teleport's point of view: any
+// existing member absent from newMembers is removed.
+func (a *AccessListService) UpsertAccessListWithMembers(ctx context.Context, list *accesslist.AccessList, newMembers []*accesslist.AccessListMember) (*accesslist.AccessList, []*accesslist.AccessListMember, error) {
+	for _, m := range newMembers {
+		if err := a.runValidators(ctx, list, m); err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+	}
+
+	updatedList, err := a.UpsertAccessList(ctx, list)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+
+	existing, _, err := a.members.listWithPrefix(ctx, list.GetName(), 0, "")
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+
+	wanted := make(map[string]struct{}, len(newMembers))
+	for _, m := range newMembers {
+		wanted[m.GetName()] = struct{}{}
+	}
+	for _, m := range existing {
+		if _, ok := wanted[m.GetName()]; !ok {
+			if err := a.members.deleteKeyed(ctx, memberKey(list.GetName(), m.GetName())); err != nil {
+				return nil, nil, trace.Wrap(err)
+			}
+		}
+	}
+
+	updatedMembers := make([]*accesslist.AccessListMember, 0, len(newMembers))
+	for _, m := range newMembers {
+		if err := a.members.upsertKeyed(ctx, memberKey(list.GetName(), m.GetName()), m); err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		updatedMembers = append(updatedMembers, m)
+	}
+
+	return updatedList, updatedMembers, nil
+}
+
+// ListAccessListReviews lists a page of reviews for the given access list.
+func (a *AccessListService) ListAccessListReviews(ctx context.Context, accessList string, pageSize int, nextToken string) ([]*accesslist.Review, string, error) {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	return a.reviews.listWithPrefix(ctx, accessList, pageSize, nextToken)
+}
+
+// CreateAccessListReview records a review, applies any genuine changes it
+// carries to the parent access list, removes any members the review flags
+// for removal, and advances the list's NextAuditDate. It returns the stored
+// review (with no-op changes normalized away) and the new NextAuditDate.
+func (a *AccessListService) CreateAccessListReview(ctx context.Context, review *accesslist.Review) (*accesslist.Review, time.Time, error) {
+	return a.createAccessListReview(ctx, review, func(list *accesslist.AccessList) error {
+		_, err := a.UpsertAccessList(ctx, list)
+		return err
+	})
+}
+
+// ConditionalCreateAccessListReview behaves like CreateAccessListReview,
+// but fails with trace.CompareFailed if the parent access list's
+// revision no longer matches expectedRevision, so two reviewers racing
+// to finish a review can't silently stomp on each other's NextAuditDate
+// recalculation.
+func (a *AccessListService) ConditionalCreateAccessListReview(ctx context.Context, review *accesslist.Review, expectedRevision string) (*accesslist.Review, time.Time, error) {
+	return a.createAccessListReview(ctx, review, func(list *accesslist.AccessList) error {
+		_, err := a.ConditionalUpdateAccessList(ctx, list, expectedRevision)
+		return err
+	})
+}
+
+func (a *AccessListService) createAccessListReview(ctx context.Context, review *accesslist.Review, persistList func(*accesslist.AccessList) error) (*accesslist.Review, time.Time, error) {
+	list, err := a.service.get(ctx, review.Spec.AccessList)
+	if err != nil {
+		return nil, time.Time{}, trace.Wrap(err)
+	}
+	prevSpec := list.Spec
+
+	changes := &review.Spec.Changes
+
+	if changes.MembershipRequirementsChanged != nil {
+		if accessListRequiresEqual(*changes.MembershipRequirementsChanged, list.Spec.MembershipRequires) {
+			changes.MembershipRequirementsChanged = nil
+		} else {
+			list.Spec.MembershipRequires = *changes.MembershipRequirementsChanged
+		}
+	}
+
+	if changes.ReviewFrequencyChanged != 0 {
+		if changes.ReviewFrequencyChanged == list.Spec.Audit.Recurrence.Frequency {
+			changes.ReviewFrequencyChanged = 0
+		} else {
+			list.Spec.Audit.Recurrence.Frequency = changes.ReviewFrequencyChanged
+		}
+	}
+
+	if changes.ReviewDayOfMonthChanged != 0 {
+		if changes.ReviewDayOfMonthChanged == list.Spec.Audit.Recurrence.DayOfMonth {
+			changes.ReviewDayOfMonthChanged = 0
+		} else {
+			list.Spec.Audit.Recurrence.DayOfMonth = changes.ReviewDayOfMonthChanged
+		}
+	}
+
+	for _, removed := range changes.RemovedMembers {
+		if err := a.members.deleteKeyed(ctx, memberKey(list.GetName(), removed)); err != nil && !trace.IsNotFound(err) {
+			return nil, time.Time{}, trace.Wrap(err)
+		}
+	}
+
+	// Re-run membership validators against every remaining member so
+	// that periodic reviews also catch members who have drifted out of
+	// compliance with external policy (e.g. an IGA-backed extender)
+	// since they were added, not just at add-time.
+	if len(a.validators) > 0 {
+		remaining, _, err := a.members.listWithPrefix(ctx, list.GetName(), 0, "")
+		if err != nil {
+			return nil, time.Time{}, trace.Wrap(err)
+		}
+		for _, m := range remaining {
+			if err := a.runValidators(ctx, list, m); err != nil {
+				if trace.IsConnectionProblem(err) {
+					// Don't treat a validator we couldn't even reach as
+					// grounds to remove every member still left to check:
+					// that would turn a transient network blip into a
+					// mass de-provisioning. Fail the review instead so it
+					// can be retried once the validator is reachable again.
+					return nil, time.Time{}, trace.Wrap(err, "re-validating members of access list %q", list.GetName())
+				}
+				if delErr := a.members.deleteKeyed(ctx, memberKey(list.GetName(), m.GetName())); delErr != nil && !trace.IsNotFound(delErr) {
+					return nil, time.Time{}, trace.Wrap(delErr)
+				}
+				changes.RemovedMembers = append(changes.RemovedMembers, m.GetName())
+			}
+		}
+	}
+
+	// Filing a review clears any grant suspension a ReviewSweeper applied
+	// while the list sat expired.
+	if err := a.restoreSuspendedGrants(ctx, list); err != nil {
+		return nil, time.Time{}, trace.Wrap(err)
+	}
+
+	next := computeNextAuditDate(list.Spec.Audit.NextAuditDate, list.Spec.Audit.Recurrence.Frequency, list.Spec.Audit.Recurrence.DayOfMonth)
+	list.Spec.Audit.NextAuditDate = next
+
+	if err := persistList(list); err != nil {
+		return nil, time.Time{}, trace.Wrap(err)
+	}
+
+	if err := a.reviews.upsertKeyed(ctx, memberKey(review.Spec.AccessList, review.GetName()), review); err != nil {
+		return nil, time.Time{}, trace.Wrap(err)
+	}
+
+	diff, err := audit.ComputeReviewDiff(ctx, &accesslist.AccessList{Spec: prevSpec}, list, changes.RemovedMembers)
+	if err != nil {
+		return nil, time.Time{}, trace.Wrap(err)
+	}
+	if err := a.storeReviewDiff(ctx, review.Spec.AccessList, review.GetName(), diff); err != nil {
+		return nil, time.Time{}, trace.Wrap(err)
+	}
+
+	return review, next, nil
+}
+
+// storeReviewDiff persists diff alongside the review it describes, as
+// its own backend item since audit.Map isn't an access list resource in
+// its own right.
+func (a *AccessListService) storeReviewDiff(ctx context.Context, listName, reviewName string, diff audit.Map) error {
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = a.service.backend.Put(ctx, backend.Item{
+		Key:   backend.Key(accessListReviewDiffPrefix, listName, reviewName),
+		Value: data,
+	})
+	return trace.Wrap(err)
+}
+
+// GetReviewDiff returns the stored field-level diff for a review,
+// suitable for rendering a human-readable change log.
+func (a *AccessListService) GetReviewDiff(ctx context.Context, listName, reviewName string) (audit.Map, error) {
+	item, err := a.service.backend.Get(ctx, backend.Key(accessListReviewDiffPrefix, listName, reviewName))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("access_list_review_diff for %q/%q doesn't exist", listName, reviewName)
+		}
+		return nil, trace.Wrap(err)
+	}
+
+	var diff audit.Map
+	if err := json.Unmarshal(item.Value, &diff); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return diff, nil
+}
+
+// DeleteAccessListReview deletes a single review.
+func (a *AccessListService) DeleteAccessListReview(ctx context.Context, accessList, reviewName string) error {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := a.reviews.get(ctx, memberKey(accessList, reviewName)); err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("access_list_review %q doesn't exist", reviewName)
+		}
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.reviews.deleteKeyed(ctx, memberKey(accessList, reviewName)))
+}
+
+// ConditionalDeleteAccessListReview deletes a review only if its current
+// backend revision still matches expectedRevision.
+func (a *AccessListService) ConditionalDeleteAccessListReview(ctx context.Context, accessList, reviewName, expectedRevision string) error {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.reviews.conditionalDelete(ctx, memberKey(accessList, reviewName), expectedRevision))
+}
+
+// DeleteAllAccessListReviews deletes every review for the given access list.
+func (a *AccessListService) DeleteAllAccessListReviews(ctx context.Context, accessList string) error {
+	if _, err := a.service.get(ctx, accessList); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.reviews.deleteAllWithPrefix(ctx, accessList))
+}
+
+// accessListRequiresEqual reports whether two Requires blocks are
+// equivalent, ignoring slice/map ordering. It delegates to the same
+// field-comparison rules ComputeReviewDiff uses, so "no change" in a
+// review and "no diff entry" in the audit trail never disagree.
+func accessListRequiresEqual(a, b accesslist.Requires) bool {
+	return audit.RequiresEqual(a, b)
+}
+
+func memberKey(accessList, name string) string {
+	return accessList + "/" + name
+}
+
+func validateUniqueOwners(list *accesslist.AccessList) error {
+	seen := make(map[string]struct{}, len(list.Spec.Owners))
+	for _, owner := range list.Spec.Owners {
+		if _, ok := seen[owner.Name]; ok {
+			return trace.AlreadyExists("owner %q is already a member of access list %q", owner.Name, list.GetName())
+		}
+		seen[owner.Name] = struct{}{}
+	}
+	return nil
+}
+
+// dedupeOwners removes duplicate owners from access lists that were
+// written to the backend before owner uniqueness was enforced.
+func dedupeOwners(list *accesslist.AccessList) {
+	seen := make(map[string]struct{}, len(list.Spec.Owners))
+	deduped := make([]accesslist.Owner, 0, len(list.Spec.Owners))
+	for _, owner := range list.Spec.Owners {
+		if _, ok := seen[owner.Name]; ok {
+			continue
+		}
+		seen[owner.Name] = struct{}{}
+		deduped = append(deduped, owner)
+	}
+	list.Spec.Owners = deduped
+}
+
+func ownerNames(list *accesslist.AccessList) []string {
+	names := make([]string, 0, len(list.Spec.Owners))
+	for _, owner := range list.Spec.Owners {
+		names = append(names, owner.Name)
+	}
+	return names
+}
+
+// ownerIndexKey builds the secondary-index key used to look up access
+// lists by owner: accessListOwnerIndexPrefix/<owner-name>/<list-name>.
+func ownerIndexKey(owner, listName string) []byte {
+	return backend.Key(accessListOwnerIndexPrefix, owner, listName)
+}
+
+// ownerNames returns the set of owners currently recorded in the backend
+// for the given access list, by reading the list itself rather than the
+// index (so it reflects reality even if the index was never built, e.g.
+// for a list that predates this feature).
+func (a *AccessListService) ownerNames(ctx context.Context, listName string) ([]string, error) {
+	list, err := a.service.get(ctx, listName)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	return ownerNames(list), nil
+}
+
+// updateOwnerIndex reconciles the owner index for listName so that it
+// contains exactly newOwners, removing any stale entries left over from
+// oldOwners. It has no preconditions on oldOwners/newOwners overlapping
+// and is safe to call with oldOwners from a prior state that no longer
+// exists in the backend.
+func (a *AccessListService) updateOwnerIndex(ctx context.Context, listName string, oldOwners, newOwners []string) error {
+	wanted := make(map[string]struct{}, len(newOwners))
+	for _, owner := range newOwners {
+		wanted[owner] = struct{}{}
+	}
+
+	for _, owner := range oldOwners {
+		if _, ok := wanted[owner]; ok {
+			continue
+		}
+		if err := a.service.backend.Delete(ctx, ownerIndexKey(owner, listName)); err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+	}
+
+	for owner := range wanted {
+		_, err := a.service.backend.Put(ctx, backend.Item{
+			Key:   ownerIndexKey(owner, listName),
+			Value: []byte(listName),
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// deleteOwnerIndex removes every entry in the owner index. Used by
+// DeleteAllAccessLists to keep the index consistent with the (now empty)
+// set of access lists.
+func (a *AccessListService) deleteOwnerIndex(ctx context.Context) error {
+	startKey := backend.ExactKey(accessListOwnerIndexPrefix)
+	return trace.Wrap(a.service.backend.DeleteRange(ctx, startKey, backend.RangeEnd(startKey)))
+}
+
+// ListAccessListsByOwner lists a page of access lists owned by the given
+// user, using the owner index so that callers don't have to page through
+// every access list in the cluster to answer "which lists does X own?".
+func (a *AccessListService) ListAccessListsByOwner(ctx context.Context, owner string, pageSize int, nextToken string) ([]*accesslist.AccessList, string, error) {
+	if pageSize <= 0 || pageSize > accessListMaxPageSize {
+		pageSize = accessListDefaultMaxPageLen
+	}
+
+	startKey := backend.ExactKey(accessListOwnerIndexPrefix, owner)
+	rangeStart := startKey
+	if nextToken != "" {
+		// nextToken is itself a full backend key captured from a previous
+		// page's result.Items (see below) -- joining it onto startKey again
+		// would double the prefix and break pagination past page one.
+		rangeStart = []byte(nextToken)
+	}
+
+	result, err := a.service.backend.GetRange(ctx, rangeStart, backend.RangeEnd(startKey), pageSize+1)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+
+	var out []*accesslist.AccessList
+	var next string
+	for i, item := range result.Items {
+		if i == pageSize {
+			next = string(item.Key)
+			break
+		}
+		list, err := a.GetAccessList(ctx, string(item.Value))
+		if err != nil {
+			if trace.IsNotFound(err) {
+				// Index entry outlived the list it pointed to; skip it
+				// rather than failing the whole page.
+				continue
+			}
+			return nil, "", trace.Wrap(err)
+		}
+		out = append(out, list)
+	}
+
+	return out, next, nil
+}
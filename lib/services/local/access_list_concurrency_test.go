@@ -0,0 +1,83 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/memory"
+)
+
+// TestConditionalUpdateAccessListConcurrent drives two goroutines racing
+// to update the same access list from the same starting revision and
+// verifies that exactly one wins while the other observes
+// trace.CompareFailed.
+func TestConditionalUpdateAccessListConcurrent(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	created, err := service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+	startingRevision := created.GetRevision()
+
+	var wg sync.WaitGroup
+	results := make([]error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			updated := newAccessList(t, "accessList1", clock)
+			updated.SetRevision(startingRevision)
+			updated.Spec.Description = "updated"
+			_, results[i] = service.ConditionalUpdateAccessList(ctx, updated, startingRevision)
+		}(i)
+	}
+	wg.Wait()
+
+	successes := 0
+	compareFailures := 0
+	for _, err := range results {
+		switch {
+		case err == nil:
+			successes++
+		case trace.IsCompareFailed(err):
+			compareFailures++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	require.Equal(t, 1, successes, "exactly one conditional update should succeed")
+	require.Equal(t, 1, compareFailures, "the losing update should see a compare-failed error")
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/memory"
+)
+
+func newNestedAccessListMember(t *testing.T, parent, child string) *accesslist.AccessListMember {
+	t.Helper()
+
+	member := newAccessListMember(t, parent, child)
+	member.Spec.MembershipKind = accesslist.MembershipKindList
+	return member
+}
+
+// TestAccessListMembersTransitive exercises diamond inheritance and cycle
+// rejection for nested access lists; the max-depth guard is covered by
+// TestAccessListMembersTransitiveMaxDepth.
+func TestAccessListMembersTransitive(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	// Diamond: top -> {mid1, mid2} -> bottom, with alice a direct member
+	// of bottom and bob a direct member of mid1. Alice should appear
+	// exactly once in top's transitive membership.
+	for _, name := range []string{"top", "mid1", "mid2", "bottom"} {
+		_, err = service.UpsertAccessList(ctx, newAccessList(t, name, clock))
+		require.NoError(t, err)
+	}
+
+	_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, "top", "mid1"))
+	require.NoError(t, err)
+	_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, "top", "mid2"))
+	require.NoError(t, err)
+	_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, "mid1", "bottom"))
+	require.NoError(t, err)
+	_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, "mid2", "bottom"))
+	require.NoError(t, err)
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, "bottom", "alice"))
+	require.NoError(t, err)
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, "mid1", "bob"))
+	require.NoError(t, err)
+
+	members, err := service.GetAccessListMembersTransitive(ctx, "top")
+	require.NoError(t, err)
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		names = append(names, m.GetName())
+	}
+	require.ElementsMatch(t, []string{"alice", "bob"}, names)
+
+	// Attempting to nest top within bottom would close a cycle and must
+	// be rejected.
+	_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, "bottom", "top"))
+	require.True(t, trace.IsBadParameter(err), "expected bad parameter error, got %v", err)
+
+	// A list cannot be nested within itself.
+	_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, "top", "top"))
+	require.True(t, trace.IsBadParameter(err), "expected bad parameter error, got %v", err)
+}
+
+// TestAccessListMembersTransitiveMaxDepth verifies that a chain deeper
+// than maxTransitiveDepth is rejected rather than walked forever.
+func TestAccessListMembersTransitiveMaxDepth(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	chainLen := maxTransitiveDepth + 2
+	names := make([]string, chainLen)
+	for i := 0; i < chainLen; i++ {
+		names[i] = fmt.Sprintf("list%d", i)
+		_, err = service.UpsertAccessList(ctx, newAccessList(t, names[i], clock))
+		require.NoError(t, err)
+	}
+	for i := 0; i < chainLen-1; i++ {
+		_, err = service.UpsertAccessListMember(ctx, newNestedAccessListMember(t, names[i], names[i+1]))
+		require.NoError(t, err)
+	}
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, names[chainLen-1], "alice"))
+	require.NoError(t, err)
+
+	_, err = service.GetAccessListMembersTransitive(ctx, names[0])
+	require.True(t, trace.IsBadParameter(err), "expected bad parameter error, got %v", err)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/api/types/header"
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/memory"
+)
+
+// TestAccessListsByOwnerIndex verifies that the owner index stays in sync
+// across upsert, owner edits, deletion, and DeleteAllAccessLists, mirroring
+// TestAccessListCRUD but asserting on ListAccessListsByOwner instead of the
+// full list.
+func TestAccessListsByOwnerIndex(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	cmpOpts := []cmp.Option{
+		cmpopts.IgnoreFields(header.Metadata{}, "ID", "Revision"),
+	}
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	accessList2 := newAccessList(t, "accessList2", clock)
+
+	// Both lists share owner "test-user1" and "test-user2".
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+	_, err = service.UpsertAccessList(ctx, accessList2)
+	require.NoError(t, err)
+
+	out, _, err := service.ListAccessListsByOwner(ctx, "test-user1", 0, "")
+	require.NoError(t, err)
+	require.Empty(t, cmp.Diff([]*accesslist.AccessList{accessList1, accessList2}, out, cmpOpts...))
+
+	out, _, err = service.ListAccessListsByOwner(ctx, "nobody", 0, "")
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	// Remove test-user2 as an owner of accessList1 and verify the index
+	// entry is cleaned up.
+	accessList1.Spec.Owners = accessList1.Spec.Owners[:1]
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	out, _, err = service.ListAccessListsByOwner(ctx, "test-user2", 0, "")
+	require.NoError(t, err)
+	require.Empty(t, cmp.Diff([]*accesslist.AccessList{accessList2}, out, cmpOpts...))
+
+	// Deleting a list removes its index entries.
+	require.NoError(t, service.DeleteAccessList(ctx, accessList1.GetName()))
+
+	out, _, err = service.ListAccessListsByOwner(ctx, "test-user1", 0, "")
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	// DeleteAllAccessLists clears the index entirely.
+	require.NoError(t, service.DeleteAllAccessLists(ctx))
+
+	out, _, err = service.ListAccessListsByOwner(ctx, "test-user1", 0, "")
+	require.NoError(t, err)
+	require.Empty(t, out)
+}
+
+// TestListAccessListsByOwnerPagination verifies that paging through results
+// with an explicit page size actually advances: a nextToken that isn't
+// honored correctly on the next call causes the same page (or none) to be
+// returned forever instead of reaching the end of the list.
+func TestListAccessListsByOwnerPagination(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	const total = 5
+	want := make([]*accesslist.AccessList, 0, total)
+	for i := 0; i < total; i++ {
+		list := newAccessList(t, fmt.Sprintf("accessList%d", i), clock)
+		_, err = service.UpsertAccessList(ctx, list)
+		require.NoError(t, err)
+		want = append(want, list)
+	}
+
+	var got []*accesslist.AccessList
+	nextToken := ""
+	for i := 0; i < total; i++ {
+		page, token, err := service.ListAccessListsByOwner(ctx, "test-user1", 2, nextToken)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, page...)
+		if token == "" {
+			break
+		}
+		nextToken = token
+	}
+
+	cmpOpts := []cmp.Option{
+		cmpopts.IgnoreFields(header.Metadata{}, "ID", "Revision"),
+		cmpopts.SortSlices(func(a, b *accesslist.AccessList) bool { return a.GetName() < b.GetName() }),
+	}
+	require.Empty(t, cmp.Diff(want, got, cmpOpts...))
+}
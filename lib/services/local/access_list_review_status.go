@@ -0,0 +1,336 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/lib/backend"
+)
+
+// ReviewStatus classifies how an access list's audit schedule stands
+// relative to now.
+type ReviewStatus string
+
+const (
+	// ReviewStatusOnTrack means the next audit is comfortably in the
+	// future.
+	ReviewStatusOnTrack ReviewStatus = "ON_TRACK"
+	// ReviewStatusDueSoon means the next audit falls within the
+	// evaluator's due-soon window.
+	ReviewStatusDueSoon ReviewStatus = "DUE_SOON"
+	// ReviewStatusOverdue means the next audit date has passed, but not
+	// by more than the evaluator's expired window.
+	ReviewStatusOverdue ReviewStatus = "OVERDUE"
+	// ReviewStatusExpired means the next audit date is more than the
+	// evaluator's expired window in the past.
+	ReviewStatusExpired ReviewStatus = "EXPIRED"
+)
+
+const (
+	accessListSuspendedGrantsPrefix = "access_list_suspended_grants"
+
+	defaultDueSoonWindow = 14 * 24 * time.Hour
+	defaultExpiredWindow = 30 * 24 * time.Hour
+)
+
+// ReviewExpirationEvaluator classifies an access list's review status
+// from its Audit block, using configurable grace windows. It's modeled
+// on the version-gated expiration evaluator used elsewhere for rollout
+// gating, but keyed off wall-clock time rather than version skew.
+type ReviewExpirationEvaluator struct {
+	dueSoonWindow time.Duration
+	expiredWindow time.Duration
+}
+
+// ReviewEvaluatorOption configures a ReviewExpirationEvaluator.
+type ReviewEvaluatorOption func(*ReviewExpirationEvaluator)
+
+// WithDueSoonWindow overrides the default 14-day due-soon window.
+func WithDueSoonWindow(d time.Duration) ReviewEvaluatorOption {
+	return func(e *ReviewExpirationEvaluator) { e.dueSoonWindow = d }
+}
+
+// WithExpiredWindow overrides the default 30-day expired window.
+func WithExpiredWindow(d time.Duration) ReviewEvaluatorOption {
+	return func(e *ReviewExpirationEvaluator) { e.expiredWindow = d }
+}
+
+// NewReviewExpirationEvaluator creates a ReviewExpirationEvaluator with
+// the default grace windows, as overridden by opts.
+func NewReviewExpirationEvaluator(opts ...ReviewEvaluatorOption) *ReviewExpirationEvaluator {
+	e := &ReviewExpirationEvaluator{
+		dueSoonWindow: defaultDueSoonWindow,
+		expiredWindow: defaultExpiredWindow,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Evaluate classifies audit's review status as of now. lastReview is
+// consulted only when audit.NextAuditDate is unset (a list that has
+// never been scheduled), in which case the next audit date is derived
+// from the review's own date and recurrence.
+func (e *ReviewExpirationEvaluator) Evaluate(now time.Time, audit accesslist.Audit, lastReview *accesslist.Review) ReviewStatus {
+	next := audit.NextAuditDate
+	if next.IsZero() && lastReview != nil {
+		next = computeNextAuditDate(lastReview.Spec.ReviewDate, audit.Recurrence.Frequency, audit.Recurrence.DayOfMonth)
+	}
+
+	until := next.Sub(now)
+	switch {
+	case until > e.dueSoonWindow:
+		return ReviewStatusOnTrack
+	case until > 0:
+		return ReviewStatusDueSoon
+	case -until <= e.expiredWindow:
+		return ReviewStatusOverdue
+	default:
+		return ReviewStatusExpired
+	}
+}
+
+// computeNextAuditDate projects prev forward by freq months, landing on
+// day, clamped to the last day of the target month when day overflows it
+// (e.g. day 31 in a 30-day month). A day of zero or less falls through
+// to time.Date's native "day before the 1st" rollback, preserving the
+// behavior lists created before day-of-month was always populated
+// already depend on. prev is normalized to UTC first, so the projection
+// is unaffected by DST transitions in whatever zone prev originally
+// carried.
+func computeNextAuditDate(prev time.Time, freq accesslist.ReviewFrequency, day accesslist.ReviewDayOfMonth) time.Time {
+	prev = prev.UTC()
+	targetYear, targetMonth := prev.Year(), prev.Month()+time.Month(freq)
+
+	targetDay := int(day)
+	if targetDay > 0 {
+		lastDayOfTargetMonth := time.Date(targetYear, targetMonth+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if targetDay > lastDayOfTargetMonth {
+			targetDay = lastDayOfTargetMonth
+		}
+	}
+
+	return time.Date(targetYear, targetMonth, targetDay, 0, 0, 0, 0, time.UTC)
+}
+
+// EvaluateReviewStatus classifies the named access list's review status
+// using its most recent review, if any.
+func (a *AccessListService) EvaluateReviewStatus(ctx context.Context, listName string) (ReviewStatus, error) {
+	list, err := a.service.get(ctx, listName)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	lastReview, err := a.mostRecentReview(ctx, listName)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	return a.reviewEvaluator().Evaluate(a.clock.Now(), list.Spec.Audit, lastReview), nil
+}
+
+// reviewEvaluator returns the service's configured evaluator, falling
+// back to the default grace windows if none was supplied at
+// construction time.
+func (a *AccessListService) reviewEvaluator() *ReviewExpirationEvaluator {
+	if a.reviewExpirationEvaluator == nil {
+		return NewReviewExpirationEvaluator()
+	}
+	return a.reviewExpirationEvaluator
+}
+
+// mostRecentReview returns the access list's latest review by
+// ReviewDate, or nil if it has none.
+func (a *AccessListService) mostRecentReview(ctx context.Context, listName string) (*accesslist.Review, error) {
+	var mostRecent *accesslist.Review
+	nextToken := ""
+	for {
+		page, token, err := a.reviews.listWithPrefix(ctx, listName, 0, nextToken)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		for _, review := range page {
+			if mostRecent == nil || review.Spec.ReviewDate.After(mostRecent.Spec.ReviewDate) {
+				mostRecent = review
+			}
+		}
+		if token == "" {
+			break
+		}
+		nextToken = token
+	}
+	return mostRecent, nil
+}
+
+// suspendGrants stashes list's current Grants under a dedicated backend
+// key and clears them on list, so that an expired-but-unreviewed list
+// stops conferring access until a review is filed. It's a no-op if
+// grants are already suspended.
+func (a *AccessListService) suspendGrants(ctx context.Context, list *accesslist.AccessList) error {
+	if _, err := a.service.backend.Get(ctx, suspendedGrantsKey(list.GetName())); err == nil {
+		return nil
+	} else if !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+
+	data, err := json.Marshal(list.Spec.Grants)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := a.service.backend.Put(ctx, backend.Item{
+		Key:   suspendedGrantsKey(list.GetName()),
+		Value: data,
+	}); err != nil {
+		return trace.Wrap(err)
+	}
+
+	list.Spec.Grants = accesslist.Grants{}
+	return nil
+}
+
+// restoreSuspendedGrants restores list's Grants from a prior
+// suspendGrants call, if any, and clears the stashed copy. It's a no-op
+// if grants were never suspended.
+func (a *AccessListService) restoreSuspendedGrants(ctx context.Context, list *accesslist.AccessList) error {
+	item, err := a.service.backend.Get(ctx, suspendedGrantsKey(list.GetName()))
+	if trace.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	var grants accesslist.Grants
+	if err := json.Unmarshal(item.Value, &grants); err != nil {
+		return trace.Wrap(err)
+	}
+	list.Spec.Grants = grants
+
+	return trace.Wrap(a.service.backend.Delete(ctx, suspendedGrantsKey(list.GetName())))
+}
+
+func suspendedGrantsKey(listName string) []byte {
+	return backend.Key(accessListSuspendedGrantsPrefix, listName)
+}
+
+// ReviewNotification describes a single list's review status as found
+// by a ReviewSweeper pass, for delivery to whatever notifies owners.
+type ReviewNotification struct {
+	AccessList string
+	Status     ReviewStatus
+}
+
+// ReviewSweeperOption configures a ReviewSweeper.
+type ReviewSweeperOption func(*ReviewSweeper)
+
+// WithSuspendExpiredGrants enables automatically suspending an expired
+// list's Grants until its next review is filed.
+func WithSuspendExpiredGrants() ReviewSweeperOption {
+	return func(s *ReviewSweeper) { s.suspendExpiredGrants = true }
+}
+
+// ReviewSweeper periodically evaluates every access list's review
+// status, emitting a ReviewNotification for each DueSoon or Expired list
+// and, optionally, suspending an expired list's Grants.
+type ReviewSweeper struct {
+	service              *AccessListService
+	notify               chan<- ReviewNotification
+	suspendExpiredGrants bool
+}
+
+// NewReviewSweeper creates a ReviewSweeper that reports findings on
+// notify. Sends are non-blocking: a full notify channel drops the
+// notification rather than stalling the sweep.
+func NewReviewSweeper(service *AccessListService, notify chan<- ReviewNotification, opts ...ReviewSweeperOption) *ReviewSweeper {
+	s := &ReviewSweeper{
+		service: service,
+		notify:  notify,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run sweeps every interval until ctx is canceled.
+func (s *ReviewSweeper) Run(ctx context.Context, interval time.Duration) error {
+	ticker := s.service.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.Chan():
+			if err := s.sweep(ctx); err != nil {
+				return trace.Wrap(err)
+			}
+		}
+	}
+}
+
+// sweep evaluates every access list once and acts on the result.
+func (s *ReviewSweeper) sweep(ctx context.Context) error {
+	lists, err := s.service.GetAccessLists(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	evaluator := s.service.reviewEvaluator()
+	for _, list := range lists {
+		lastReview, err := s.service.mostRecentReview(ctx, list.GetName())
+		if err != nil {
+			return trace.Wrap(err)
+		}
+
+		status := evaluator.Evaluate(s.service.clock.Now(), list.Spec.Audit, lastReview)
+		switch status {
+		case ReviewStatusDueSoon, ReviewStatusExpired:
+			s.sendNotification(ReviewNotification{AccessList: list.GetName(), Status: status})
+		}
+
+		if status != ReviewStatusExpired || !s.suspendExpiredGrants {
+			continue
+		}
+		if err := s.service.suspendGrants(ctx, list); err != nil {
+			return trace.Wrap(err)
+		}
+		if _, err := s.service.UpsertAccessList(ctx, list); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// sendNotification delivers n without blocking the sweep if the
+// notify channel is unbuffered or full.
+func (s *ReviewSweeper) sendNotification(n ReviewNotification) {
+	if s.notify == nil {
+		return
+	}
+	select {
+	case s.notify <- n:
+	default:
+	}
+}
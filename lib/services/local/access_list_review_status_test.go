@@ -0,0 +1,92 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/memory"
+)
+
+func TestEvaluateReviewStatus(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{Context: ctx, Clock: clock})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	accessList1.Spec.Audit.NextAuditDate = clock.Now().Add(time.Hour)
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	status, err := service.EvaluateReviewStatus(ctx, "accessList1")
+	require.NoError(t, err)
+	require.Equal(t, ReviewStatusDueSoon, status)
+}
+
+func TestReviewSweeperSuspendsExpiredGrants(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{Context: ctx, Clock: clock})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	accessList1.Spec.Grants.Roles = []string{"prod-access"}
+	accessList1.Spec.Audit.NextAuditDate = clock.Now().Add(-60 * 24 * time.Hour)
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	notify := make(chan ReviewNotification, 10)
+	sweeper := NewReviewSweeper(service, notify, WithSuspendExpiredGrants())
+	require.NoError(t, sweeper.sweep(ctx))
+
+	updated, err := service.GetAccessList(ctx, "accessList1")
+	require.NoError(t, err)
+	require.Empty(t, updated.Spec.Grants.Roles, "grants should be suspended once a list is expired")
+
+	select {
+	case n := <-notify:
+		require.Equal(t, "accessList1", n.AccessList)
+		require.Equal(t, ReviewStatusExpired, n.Status)
+	default:
+		t.Fatal("expected an expired notification")
+	}
+
+	// Filing a review restores the suspended grants.
+	review := newAccessListReview(t, "accessList1", "review1")
+
+	_, _, err = service.CreateAccessListReview(ctx, review)
+	require.NoError(t, err)
+
+	restored, err := service.GetAccessList(ctx, "accessList1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"prod-access"}, restored.Spec.Grants.Roles)
+}
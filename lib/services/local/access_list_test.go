@@ -713,6 +713,116 @@ func TestAccessListRequiresEqual(t *testing.T) {
 	}
 }
 
+func TestComputeNextAuditDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     time.Time
+		freq     accesslist.ReviewFrequency
+		day      accesslist.ReviewDayOfMonth
+		expected time.Time
+	}{
+		{
+			name:     "quarterly on the 15th",
+			prev:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			freq:     accesslist.ThreeMonths,
+			day:      accesslist.FifteenthDayOfMonth,
+			expected: time.Date(2024, time.April, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "monthly rolls over into the next year",
+			prev:     time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC),
+			freq:     accesslist.OneMonth,
+			day:      accesslist.FirstDayOfMonth,
+			expected: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "annual cadence preserves the month",
+			prev:     time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC),
+			freq:     accesslist.OneYear,
+			day:      accesslist.FifteenthDayOfMonth,
+			expected: time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of month clamps to the shorter target month",
+			prev:     time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+			freq:     accesslist.OneMonth,
+			day:      accesslist.ReviewDayOfMonth(31),
+			expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of month clamps in a non-leap February",
+			prev:     time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC),
+			freq:     accesslist.OneMonth,
+			day:      accesslist.ReviewDayOfMonth(31),
+			expected: time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "a zero day of month preserves the legacy day-before-the-1st rollback",
+			prev:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			freq:     accesslist.OneMonth,
+			day:      accesslist.ReviewDayOfMonth(0),
+			expected: time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// 2024-03-31T23:30-05:00 is 2024-04-01T04:30Z: the UTC
+			// conversion pushes the date into April, so the projection
+			// must be based on April, not March.
+			name:     "an input in a non-UTC zone normalizes before projecting forward",
+			prev:     time.Date(2024, time.March, 31, 23, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60)),
+			freq:     accesslist.ThreeMonths,
+			day:      accesslist.FirstDayOfMonth,
+			expected: time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			require.True(t, test.expected.Equal(computeNextAuditDate(test.prev, test.freq, test.day)))
+		})
+	}
+}
+
+func TestReviewExpirationEvaluator(t *testing.T) {
+	nextAuditDate := time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected ReviewStatus
+	}{
+		{
+			name:     "well before the audit date",
+			now:      time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC),
+			expected: ReviewStatusOnTrack,
+		},
+		{
+			name:     "inside the due-soon window",
+			now:      time.Date(2024, time.June, 5, 0, 0, 0, 0, time.UTC),
+			expected: ReviewStatusDueSoon,
+		},
+		{
+			name:     "past the audit date but inside the expired window",
+			now:      time.Date(2024, time.June, 20, 0, 0, 0, 0, time.UTC),
+			expected: ReviewStatusOverdue,
+		},
+		{
+			name:     "past the expired window",
+			now:      time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC),
+			expected: ReviewStatusExpired,
+		},
+	}
+
+	evaluator := NewReviewExpirationEvaluator()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			audit := accesslist.Audit{NextAuditDate: nextAuditDate}
+			require.Equal(t, test.expected, evaluator.Evaluate(test.now, audit, nil))
+		})
+	}
+}
+
 func newAccessList(t *testing.T, name string, clock clockwork.Clock) *accesslist.AccessList {
 	t.Helper()
 
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/api/types/accesslist"
+)
+
+// MembershipValidator is implemented by pluggable checks that decide
+// whether a candidate member may join an access list. Validators let
+// operators delegate the decision to systems Teleport doesn't know
+// about (a SCIM-backed IGA, a ticketing/approval workflow) without
+// forking the access list service.
+type MembershipValidator interface {
+	// Validate returns a non-nil error if member should not be allowed
+	// to join list.
+	Validate(ctx context.Context, list *accesslist.AccessList, member *accesslist.AccessListMember) error
+}
+
+// UserGetter is the subset of the user store RequirementsValidator needs
+// to check a candidate member's roles and traits against an access
+// list's MembershipRequires.
+type UserGetter interface {
+	GetUser(ctx context.Context, name string, withSecrets bool) (types.User, error)
+}
+
+// RequirementsValidator is the built-in validator enforcing an access
+// list's static MembershipRequires (roles and traits) against the
+// candidate member's backing Teleport user. It reproduces the implicit
+// enforcement that existed before validators became pluggable.
+type RequirementsValidator struct {
+	Users UserGetter
+}
+
+// Validate implements MembershipValidator.
+func (r *RequirementsValidator) Validate(ctx context.Context, list *accesslist.AccessList, member *accesslist.AccessListMember) error {
+	// Nested lists are themselves subject to MembershipRequires at the
+	// point their own members are added; there's no single backing user
+	// here to check roles/traits against.
+	if member.Spec.MembershipKind == accesslist.MembershipKindList {
+		return nil
+	}
+
+	user, err := r.Users.GetUser(ctx, member.GetName(), false)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	requires := list.Spec.MembershipRequires
+	if !containsAll(user.GetRoles(), requires.Roles) {
+		return trace.AccessDenied("user %q does not hold all roles required by access list %q", member.GetName(), list.GetName())
+	}
+	if !traitsSatisfy(user.GetTraits(), requires.Traits) {
+		return trace.AccessDenied("user %q does not have all traits required by access list %q", member.GetName(), list.GetName())
+	}
+
+	return nil
+}
+
+func containsAll(haystack, needles []string) bool {
+	set := make(map[string]struct{}, len(haystack))
+	for _, v := range haystack {
+		set[v] = struct{}{}
+	}
+	for _, needle := range needles {
+		if _, ok := set[needle]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func traitsSatisfy(have, want map[string][]string) bool {
+	for trait, wantValues := range want {
+		haveValues, ok := have[trait]
+		if !ok {
+			return false
+		}
+		if !containsAll(haveValues, wantValues) {
+			return false
+		}
+	}
+	return true
+}
+
+// HTTPExtenderValidator delegates the membership decision to an external
+// system by POSTing the candidate member as JSON to URL and failing
+// closed on any response that isn't 2xx.
+type HTTPExtenderValidator struct {
+	// URL is the endpoint the candidate member is POSTed to.
+	URL string
+	// Client is used to make the request. Defaults to http.DefaultClient
+	// if nil.
+	Client *http.Client
+}
+
+type httpExtenderRequest struct {
+	AccessList string `json:"access_list"`
+	Member     string `json:"member"`
+}
+
+// Validate implements MembershipValidator.
+func (h *HTTPExtenderValidator) Validate(ctx context.Context, list *accesslist.AccessList, member *accesslist.AccessListMember) error {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(httpExtenderRequest{
+		AccessList: list.GetName(),
+		Member:     member.GetName(),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return trace.ConnectionProblem(err, "contacting membership extender %q", h.URL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return trace.AccessDenied("membership extender %q rejected %q for access list %q (status %d)", h.URL, member.GetName(), list.GetName(), resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/memory"
+)
+
+// fakeMembershipValidator is a MembershipValidator double for tests; it
+// rejects any member whose name is in reject.
+type fakeMembershipValidator struct {
+	reject map[string]struct{}
+}
+
+func (f *fakeMembershipValidator) Validate(_ context.Context, list *accesslist.AccessList, member *accesslist.AccessListMember) error {
+	if _, ok := f.reject[member.GetName()]; ok {
+		return trace.AccessDenied("member %q rejected by fake validator", member.GetName())
+	}
+	return nil
+}
+
+func TestAccessListMembershipValidators(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	validator := &fakeMembershipValidator{reject: map[string]struct{}{"eve": {}}}
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock, WithMembershipValidators(validator))
+	require.NoError(t, err)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	// alice passes the validator.
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, accessList1.GetName(), "alice"))
+	require.NoError(t, err)
+
+	// eve is rejected.
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, accessList1.GetName(), "eve"))
+	require.True(t, trace.IsAccessDenied(err), "expected access denied error, got %v", err)
+
+	_, err = service.GetAccessListMember(ctx, accessList1.GetName(), "eve")
+	require.True(t, trace.IsNotFound(err), "eve should not have been written")
+
+	// UpsertAccessListWithMembers rejects the whole batch if any member
+	// fails validation.
+	_, _, err = service.UpsertAccessListWithMembers(ctx, accessList1, []*accesslist.AccessListMember{
+		newAccessListMember(t, accessList1.GetName(), "alice"),
+		newAccessListMember(t, accessList1.GetName(), "eve"),
+	})
+	require.True(t, trace.IsAccessDenied(err), "expected access denied error, got %v", err)
+}
+
+// TestAccessListReviewRevalidatesMembers verifies that CreateAccessListReview
+// re-runs validators and auto-removes members who no longer satisfy them.
+func TestAccessListReviewRevalidatesMembers(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	validator := &fakeMembershipValidator{reject: map[string]struct{}{}}
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock, WithMembershipValidators(validator))
+	require.NoError(t, err)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, accessList1.GetName(), "alice"))
+	require.NoError(t, err)
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, accessList1.GetName(), "bob"))
+	require.NoError(t, err)
+
+	// Policy changes out-of-band: bob no longer qualifies.
+	validator.reject["bob"] = struct{}{}
+
+	review := newAccessListReview(t, accessList1.GetName(), "review1")
+	review.Spec.Changes.MembershipRequirementsChanged = nil
+	review.Spec.Changes.RemovedMembers = nil
+	review.Spec.Changes.ReviewFrequencyChanged = 0
+	review.Spec.Changes.ReviewDayOfMonthChanged = 0
+
+	_, _, err = service.CreateAccessListReview(ctx, review)
+	require.NoError(t, err)
+
+	_, err = service.GetAccessListMember(ctx, accessList1.GetName(), "alice")
+	require.NoError(t, err)
+
+	_, err = service.GetAccessListMember(ctx, accessList1.GetName(), "bob")
+	require.True(t, trace.IsNotFound(err), "bob should have been auto-removed by revalidation")
+}
+
+// unreachableMembershipValidator is a MembershipValidator double that
+// simulates an external validator (e.g. HTTPExtenderValidator) going
+// unreachable, as opposed to being reached and rejecting the member.
+type unreachableMembershipValidator struct {
+	unreachable bool
+}
+
+func (u *unreachableMembershipValidator) Validate(context.Context, *accesslist.AccessList, *accesslist.AccessListMember) error {
+	if u.unreachable {
+		return trace.ConnectionProblem(nil, "dialing membership extender")
+	}
+	return nil
+}
+
+// TestAccessListReviewDoesNotRemoveMembersOnConnectionProblem is the
+// regression test for chunk1-3: a transient failure to reach a validator
+// must not be treated as every member having failed validation, or a
+// single network blip during a review would mass-remove the whole list.
+func TestAccessListReviewDoesNotRemoveMembersOnConnectionProblem(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	validator := &unreachableMembershipValidator{}
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock, WithMembershipValidators(validator))
+	require.NoError(t, err)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	_, err = service.UpsertAccessListMember(ctx, newAccessListMember(t, accessList1.GetName(), "alice"))
+	require.NoError(t, err)
+
+	// The extender goes unreachable right as the review is filed.
+	validator.unreachable = true
+
+	review := newAccessListReview(t, accessList1.GetName(), "review1")
+	review.Spec.Changes.MembershipRequirementsChanged = nil
+	review.Spec.Changes.RemovedMembers = nil
+	review.Spec.Changes.ReviewFrequencyChanged = 0
+	review.Spec.Changes.ReviewDayOfMonthChanged = 0
+
+	_, _, err = service.CreateAccessListReview(ctx, review)
+	require.True(t, trace.IsConnectionProblem(err), "expected connection problem error, got %v", err)
+
+	_, err = service.GetAccessListMember(ctx, accessList1.GetName(), "alice")
+	require.NoError(t, err, "alice should not have been removed on a transient validator connection failure")
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/services"
+)
+
+// accessListWatcherDefaultQueueSize is the Events() channel buffer size
+// used when WatcherConfig.QueueSize is unset.
+const accessListWatcherDefaultQueueSize = 64
+
+// AccessListEventOp identifies what kind of change an AccessListEvent
+// describes.
+type AccessListEventOp int
+
+const (
+	// OpPut is emitted for both creates and updates.
+	OpPut AccessListEventOp = iota
+	// OpDelete is emitted when a resource is removed.
+	OpDelete
+	// OpInit is emitted once, after the watcher has finished replaying
+	// the current state of the watched prefixes.
+	OpInit
+	// OpReset is emitted when the consumer fell behind and buffered
+	// events were dropped; the consumer must treat this like starting a
+	// fresh watcher (re-fetch state) rather than assume continuity.
+	OpReset
+)
+
+// AccessListEvent describes a single change to an access list, member, or
+// review. Exactly one of AccessList, Member, or Review is set for Put and
+// Delete events; none are set for Init or Reset.
+type AccessListEvent struct {
+	Op         AccessListEventOp
+	AccessList *accesslist.AccessList
+	Member     *accesslist.AccessListMember
+	Review     *accesslist.Review
+	Revision   string
+}
+
+// WatcherConfig configures an AccessListWatcher.
+type WatcherConfig struct {
+	// AccessLists restricts the watcher to the named access lists (and
+	// their members/reviews). When empty, every access list is watched.
+	AccessLists []string
+	// QueueSize sets the size of the Events() channel buffer. Defaults
+	// to accessListWatcherDefaultQueueSize.
+	QueueSize int
+}
+
+// AccessListWatcher streams AccessListEvents translated from the
+// underlying backend.Watcher on the access_list/, access_list_member/,
+// and access_list_review/ prefixes.
+type AccessListWatcher struct {
+	backendWatcher backend.Watcher
+	events         chan AccessListEvent
+}
+
+// NewAccessListWatcher starts a watcher over access list, member, and
+// review changes, optionally scoped to cfg.AccessLists.
+func (a *AccessListService) NewAccessListWatcher(ctx context.Context, cfg WatcherConfig) (*AccessListWatcher, error) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = accessListWatcherDefaultQueueSize
+	}
+
+	var prefixes [][]byte
+	if len(cfg.AccessLists) == 0 {
+		prefixes = [][]byte{
+			backend.ExactKey(accessListPrefix),
+			backend.ExactKey(accessListMemberPrefix),
+			backend.ExactKey(accessListReviewPrefix),
+		}
+	} else {
+		for _, name := range cfg.AccessLists {
+			prefixes = append(prefixes,
+				backend.ExactKey(accessListPrefix, name),
+				backend.ExactKey(accessListMemberPrefix, name),
+				backend.ExactKey(accessListReviewPrefix, name),
+			)
+		}
+	}
+
+	backendWatcher, err := a.service.backend.NewWatcher(ctx, backend.Watch{
+		Name:      "access-list",
+		Prefixes:  prefixes,
+		QueueSize: cfg.QueueSize,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	w := &AccessListWatcher{
+		backendWatcher: backendWatcher,
+		events:         make(chan AccessListEvent, cfg.QueueSize),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+// Events returns the channel AccessListEvents are delivered on. The
+// channel is closed once the underlying backend watcher exits.
+func (w *AccessListWatcher) Events() <-chan AccessListEvent {
+	return w.events
+}
+
+// Close stops the watcher and releases its backend resources.
+func (w *AccessListWatcher) Close() error {
+	return w.backendWatcher.Close()
+}
+
+func (w *AccessListWatcher) run() {
+	defer close(w.events)
+	defer w.backendWatcher.Close()
+
+	for {
+		select {
+		case <-w.backendWatcher.Done():
+			return
+		case event, ok := <-w.backendWatcher.Events():
+			if !ok {
+				return
+			}
+			translated, ok := translateAccessListEvent(event)
+			if !ok {
+				continue
+			}
+			w.send(translated)
+		}
+	}
+}
+
+// send delivers event to the consumer without blocking. If the
+// consumer's channel is full, every buffered event is dropped and
+// replaced with a single OpReset so the consumer knows to resync instead
+// of silently missing updates.
+func (w *AccessListWatcher) send(event AccessListEvent) {
+	select {
+	case w.events <- event:
+		return
+	default:
+	}
+
+	for {
+		select {
+		case <-w.events:
+			continue
+		default:
+		}
+		break
+	}
+	select {
+	case w.events <- AccessListEvent{Op: OpReset}:
+	default:
+	}
+}
+
+func translateAccessListEvent(event backend.Event) (AccessListEvent, bool) {
+	switch event.Type {
+	case types.OpInit:
+		return AccessListEvent{Op: OpInit}, true
+	case types.OpPut, types.OpDelete:
+	default:
+		return AccessListEvent{}, false
+	}
+
+	op := OpPut
+	if event.Type == types.OpDelete {
+		op = OpDelete
+	}
+
+	out := AccessListEvent{Op: op, Revision: event.Item.Revision}
+	key := string(event.Item.Key)
+
+	switch {
+	case strings.HasPrefix(key, string(backend.ExactKey(accessListPrefix))):
+		if op == OpPut {
+			list, err := services.UnmarshalAccessList(event.Item.Value)
+			if err != nil {
+				return AccessListEvent{}, false
+			}
+			out.AccessList = list
+		}
+	case strings.HasPrefix(key, string(backend.ExactKey(accessListMemberPrefix))):
+		if op == OpPut {
+			member, err := services.UnmarshalAccessListMember(event.Item.Value)
+			if err != nil {
+				return AccessListEvent{}, false
+			}
+			out.Member = member
+		}
+	case strings.HasPrefix(key, string(backend.ExactKey(accessListReviewPrefix))):
+		if op == OpPut {
+			review, err := services.UnmarshalAccessListReview(event.Item.Value)
+			if err != nil {
+				return AccessListEvent{}, false
+			}
+			out.Review = review
+		}
+	default:
+		return AccessListEvent{}, false
+	}
+
+	return out, true
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/teleport/lib/backend/memory"
+)
+
+func requireAccessListEvent(t *testing.T, events <-chan AccessListEvent) AccessListEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for access list event")
+		return AccessListEvent{}
+	}
+}
+
+// TestAccessListWatcher drives member CRUD through AccessListService and
+// asserts that the watcher delivers an Init followed by the ordered
+// Put/Delete events.
+func TestAccessListWatcher(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+
+	mem, err := memory.New(memory.Config{
+		Context: ctx,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	service, err := NewAccessListService(backend.NewSanitizer(mem), clock)
+	require.NoError(t, err)
+
+	watcher, err := service.NewAccessListWatcher(ctx, WatcherConfig{})
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.Equal(t, OpInit, requireAccessListEvent(t, watcher.Events()).Op)
+
+	accessList1 := newAccessList(t, "accessList1", clock)
+	_, err = service.UpsertAccessList(ctx, accessList1)
+	require.NoError(t, err)
+
+	event := requireAccessListEvent(t, watcher.Events())
+	require.Equal(t, OpPut, event.Op)
+	require.NotNil(t, event.AccessList)
+	require.Equal(t, accessList1.GetName(), event.AccessList.GetName())
+
+	member := newAccessListMember(t, accessList1.GetName(), "alice")
+	_, err = service.UpsertAccessListMember(ctx, member)
+	require.NoError(t, err)
+
+	event = requireAccessListEvent(t, watcher.Events())
+	require.Equal(t, OpPut, event.Op)
+	require.NotNil(t, event.Member)
+	require.Equal(t, "alice", event.Member.GetName())
+
+	require.NoError(t, service.DeleteAccessListMember(ctx, accessList1.GetName(), "alice"))
+
+	event = requireAccessListEvent(t, watcher.Events())
+	require.Equal(t, OpDelete, event.Op)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit computes canonical, deterministic diffs between two
+// AccessList spec states for storage alongside an access list Review. It
+// is driven by a small per-field registry rather than hand-rolled
+// equality checks, so adding a newly-tracked field is a one-line
+// addition instead of a new comparison function.
+package audit
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+)
+
+// redacted is stored in place of a secret field's actual value.
+const redacted = "[REDACTED]"
+
+// Kind describes how two values of a tracked field should be compared.
+type Kind int
+
+const (
+	// KindScalar fields are compared with reflect.DeepEqual.
+	KindScalar Kind = iota
+	// KindSet fields ([]string) are compared as unordered sets.
+	KindSet
+	// KindMap fields (map[string][]string) are compared key-by-key, with
+	// each value compared as an unordered set.
+	KindMap
+)
+
+// FieldSpec declares how a single dotted field path on accesslist.Spec
+// should be diffed.
+type FieldSpec struct {
+	// Path is a dotted path of exported field names, e.g.
+	// "MembershipRequires.Roles".
+	Path string
+	// Kind selects the comparison strategy.
+	Kind Kind
+	// Secret fields have their actual values redacted in the resulting
+	// Change; only the fact that they changed is recorded.
+	Secret bool
+}
+
+// registry is the field-level diff contract for accesslist.Spec. Adding
+// a newly-tracked field means adding a line here, not a new comparison
+// function.
+var registry = []FieldSpec{
+	{Path: "MembershipRequires.Roles", Kind: KindSet},
+	{Path: "MembershipRequires.Traits", Kind: KindMap, Secret: true},
+	{Path: "OwnershipRequires.Roles", Kind: KindSet},
+	{Path: "OwnershipRequires.Traits", Kind: KindMap, Secret: true},
+	{Path: "Grants.Roles", Kind: KindSet},
+	{Path: "Grants.Traits", Kind: KindMap, Secret: true},
+	{Path: "Audit.Recurrence.Frequency", Kind: KindScalar},
+	{Path: "Audit.Recurrence.DayOfMonth", Kind: KindScalar},
+}
+
+// Change records the before/after value of a single field. Set and Map
+// fields are normalized (sorted) before being recorded so that the diff
+// is deterministic regardless of original slice/map ordering.
+type Change struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// Map is a canonical diff: field path -> its Change.
+type Map map[string]Change
+
+// KV is a sorted (key, values) pair used to render a KindMap field's
+// value deterministically.
+type KV struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// ComputeReviewDiff walks the registered fields of prev and next,
+// recording a Change for every field whose value actually differs, plus
+// a synthetic "RemovedMembers" entry when removed is non-empty.
+func ComputeReviewDiff(ctx context.Context, prev, next *accesslist.AccessList, removed []string) (Map, error) {
+	diff := make(Map)
+
+	for _, spec := range registry {
+		oldVal, err := fieldByPath(prev.Spec, spec.Path)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		newVal, err := fieldByPath(next.Spec, spec.Path)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+
+		oldRendered, oldCanon := render(spec.Kind, oldVal)
+		newRendered, newCanon := render(spec.Kind, newVal)
+		if reflect.DeepEqual(oldCanon, newCanon) {
+			continue
+		}
+
+		if spec.Secret {
+			diff[spec.Path] = Change{Old: redacted, New: redacted}
+			continue
+		}
+		diff[spec.Path] = Change{Old: oldRendered, New: newRendered}
+	}
+
+	if len(removed) > 0 {
+		diff["RemovedMembers"] = Change{New: normalizeSet(removed)}
+	}
+
+	return diff, nil
+}
+
+// fieldByPath resolves a dotted field path against v, dereferencing
+// pointers as it goes. A nil pointer anywhere in the chain resolves to
+// an invalid (zero) reflect.Value rather than an error, so absent
+// optional structs diff cleanly against a present one.
+func fieldByPath(v any, path string) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for _, part := range strings.Split(path, ".") {
+		for rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, nil
+			}
+			rv = rv.Elem()
+		}
+		if !rv.IsValid() {
+			return reflect.Value{}, nil
+		}
+		if rv.Kind() != reflect.Struct {
+			return reflect.Value{}, trace.BadParameter("audit: field path %q does not resolve to a struct", path)
+		}
+		rv = rv.FieldByName(part)
+		if !rv.IsValid() {
+			return reflect.Value{}, trace.BadParameter("audit: unknown field %q in path %q", part, path)
+		}
+	}
+	return rv, nil
+}
+
+// render returns both a human/JSON-friendly rendering of rv (used in the
+// stored Change) and a canonical form used purely for equality (so nil
+// and empty slices/maps compare equal).
+func render(kind Kind, rv reflect.Value) (rendered, canonical any) {
+	var raw any
+	if rv.IsValid() && !rv.IsZero() {
+		raw = rv.Interface()
+	}
+
+	switch kind {
+	case KindSet:
+		values, _ := raw.([]string)
+		normalized := normalizeSet(values)
+		return normalized, normalized
+	case KindMap:
+		values, _ := raw.(map[string][]string)
+		normalized := normalizeMap(values)
+		return normalized, normalized
+	default:
+		return raw, raw
+	}
+}
+
+// normalizeSet returns a sorted copy of values with nil/empty treated
+// identically.
+func normalizeSet(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := append([]string(nil), values...)
+	sort.Strings(out)
+	return out
+}
+
+// normalizeMap renders a map[string][]string as a key-sorted slice of KV
+// pairs (each with a sorted value set), so the resulting diff is stable
+// across map iteration order.
+func normalizeMap(m map[string][]string) []KV {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]KV, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, KV{Key: k, Values: normalizeSet(m[k])})
+	}
+	return out
+}
+
+// RequiresEqual reports whether two Requires blocks are equivalent,
+// ignoring slice/map ordering. It normalizes roles and traits with the
+// same normalizeSet/normalizeMap helpers ComputeReviewDiff applies to
+// KindSet and KindMap fields, so the two agree on what counts as a change.
+func RequiresEqual(a, b accesslist.Requires) bool {
+	if !reflect.DeepEqual(normalizeSet(a.Roles), normalizeSet(b.Roles)) {
+		return false
+	}
+	return reflect.DeepEqual(normalizeMap(a.Traits), normalizeMap(b.Traits))
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+)
+
+func TestComputeReviewDiff(t *testing.T) {
+	prev := &accesslist.AccessList{
+		Spec: accesslist.Spec{
+			MembershipRequires: accesslist.Requires{
+				Roles: []string{"role1", "role2"},
+				Traits: map[string][]string{
+					"trait1": {"a", "b"},
+				},
+			},
+			Audit: accesslist.Audit{
+				Recurrence: accesslist.Recurrence{
+					Frequency:  accesslist.OneMonth,
+					DayOfMonth: accesslist.FirstDayOfMonth,
+				},
+			},
+		},
+	}
+
+	next := &accesslist.AccessList{
+		Spec: accesslist.Spec{
+			MembershipRequires: accesslist.Requires{
+				// Same roles, different order: should not diff.
+				Roles: []string{"role2", "role1"},
+				Traits: map[string][]string{
+					"trait1": {"a", "b"},
+				},
+			},
+			Audit: accesslist.Audit{
+				Recurrence: accesslist.Recurrence{
+					Frequency:  accesslist.ThreeMonths,
+					DayOfMonth: accesslist.FifteenthDayOfMonth,
+				},
+			},
+		},
+	}
+
+	diff, err := ComputeReviewDiff(context.Background(), prev, next, []string{"bob", "alice"})
+	require.NoError(t, err)
+
+	require.NotContains(t, diff, "MembershipRequires.Roles")
+
+	require.Contains(t, diff, "Audit.Recurrence.Frequency")
+	require.Equal(t, accesslist.OneMonth, diff["Audit.Recurrence.Frequency"].Old)
+	require.Equal(t, accesslist.ThreeMonths, diff["Audit.Recurrence.Frequency"].New)
+
+	require.Contains(t, diff, "Audit.Recurrence.DayOfMonth")
+
+	require.Contains(t, diff, "RemovedMembers")
+	require.Equal(t, []string{"alice", "bob"}, diff["RemovedMembers"].New)
+}
+
+func TestComputeReviewDiffRedactsSecrets(t *testing.T) {
+	prev := &accesslist.AccessList{
+		Spec: accesslist.Spec{
+			MembershipRequires: accesslist.Requires{
+				Traits: map[string][]string{"trait1": {"a"}},
+			},
+		},
+	}
+	next := &accesslist.AccessList{
+		Spec: accesslist.Spec{
+			MembershipRequires: accesslist.Requires{
+				Traits: map[string][]string{"trait1": {"b"}},
+			},
+		},
+	}
+
+	diff, err := ComputeReviewDiff(context.Background(), prev, next, nil)
+	require.NoError(t, err)
+
+	require.Contains(t, diff, "MembershipRequires.Traits")
+	require.Equal(t, redacted, diff["MembershipRequires.Traits"].Old)
+	require.Equal(t, redacted, diff["MembershipRequires.Traits"].New)
+}
+
+func TestRequiresEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     accesslist.Requires
+		expected bool
+	}{
+		{
+			name:     "both empty",
+			expected: true,
+		},
+		{
+			name: "same roles different order",
+			a:    accesslist.Requires{Roles: []string{"a", "b"}},
+			b:    accesslist.Requires{Roles: []string{"b", "a"}},
+			expected: true,
+		},
+		{
+			name:     "different roles",
+			a:        accesslist.Requires{Roles: []string{"a"}},
+			b:        accesslist.Requires{Roles: []string{"b"}},
+			expected: false,
+		},
+		{
+			name: "same traits different order",
+			a: accesslist.Requires{
+				Traits: map[string][]string{"t1": {"v1", "v2"}},
+			},
+			b: accesslist.Requires{
+				Traits: map[string][]string{"t1": {"v2", "v1"}},
+			},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, RequiresEqual(test.a, test.b))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ical renders a caller's access lists as an iCalendar (RFC 5545)
+// feed: one VTODO per list tracking its next scheduled audit, plus
+// optional VEVENT reminders for member expiry dates. It has no knowledge
+// of HTTP or CalDAV; callers wire RenderCalendar's output into whatever
+// transport they need.
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+)
+
+const (
+	dateTimeLayout = "20060102T150405Z"
+	prodID         = "-//Teleport//Access Lists//EN"
+)
+
+// MemberExpiry is an optional member expiration to render as a VEVENT
+// reminder alongside its access list's VTODO.
+type MemberExpiry struct {
+	// Member is the expiring member's name.
+	Member string
+	// Expires is when the membership expires.
+	Expires time.Time
+}
+
+// RenderCalendar renders lists as a VCALENDAR containing one VTODO per
+// list (its next scheduled audit) and one VEVENT per entry in expiries
+// (a member's upcoming expiration). now is used to stamp DTSTAMP on
+// every generated component.
+func RenderCalendar(lists []*accesslist.AccessList, expiries map[string][]MemberExpiry, now time.Time) (string, error) {
+	var b strings.Builder
+
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	fmt.Fprintf(&b, "PRODID:%s\r\n", prodID)
+	b.WriteString("CALSCALE:GREGORIAN\r\n")
+
+	for _, list := range lists {
+		if err := writeAuditTodo(&b, list, now); err != nil {
+			return "", trace.Wrap(err)
+		}
+		for _, exp := range expiries[list.GetName()] {
+			writeExpiryEvent(&b, list, exp, now)
+		}
+	}
+
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String(), nil
+}
+
+// writeAuditTodo writes a single VTODO for list's next audit.
+func writeAuditTodo(b *strings.Builder, list *accesslist.AccessList, now time.Time) error {
+	rrule, err := rruleFor(list.Spec.Audit.Recurrence)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	due := list.Spec.Audit.NextAuditDate.UTC()
+
+	b.WriteString("BEGIN:VTODO\r\n")
+	fmt.Fprintf(b, "UID:audit-%s@teleport\r\n", list.GetName())
+	fmt.Fprintf(b, "DTSTAMP:%s\r\n", formatTime(now))
+	fmt.Fprintf(b, "DTSTART:%s\r\n", formatTime(due))
+	fmt.Fprintf(b, "DUE:%s\r\n", formatTime(due))
+	fmt.Fprintf(b, "RRULE:%s\r\n", rrule)
+	fmt.Fprintf(b, "SUMMARY:Audit access list \"%s\"\r\n", escapeText(list.Spec.Title))
+	if len(list.Spec.Grants.Roles) > 0 {
+		categories := make([]string, len(list.Spec.Grants.Roles))
+		for i, role := range list.Spec.Grants.Roles {
+			categories[i] = escapeText(role)
+		}
+		fmt.Fprintf(b, "CATEGORIES:%s\r\n", strings.Join(categories, ","))
+	}
+	for _, owner := range list.Spec.Owners {
+		fmt.Fprintf(b, "ATTENDEE;CN=%s:mailto:%s\r\n", escapeText(owner.Name), owner.Name)
+	}
+	b.WriteString("END:VTODO\r\n")
+	return nil
+}
+
+// writeExpiryEvent writes a single VEVENT reminding of a member's
+// upcoming expiry from list.
+func writeExpiryEvent(b *strings.Builder, list *accesslist.AccessList, exp MemberExpiry, now time.Time) {
+	expires := exp.Expires.UTC()
+
+	b.WriteString("BEGIN:VEVENT\r\n")
+	fmt.Fprintf(b, "UID:expiry-%s-%s@teleport\r\n", list.GetName(), exp.Member)
+	fmt.Fprintf(b, "DTSTAMP:%s\r\n", formatTime(now))
+	fmt.Fprintf(b, "DTSTART:%s\r\n", formatTime(expires))
+	fmt.Fprintf(b, "DTEND:%s\r\n", formatTime(expires))
+	fmt.Fprintf(b, "SUMMARY:%s's membership in \"%s\" expires\r\n", escapeText(exp.Member), escapeText(list.Spec.Title))
+	b.WriteString("END:VEVENT\r\n")
+}
+
+// rruleFor derives an RRULE from a review recurrence, e.g.
+// FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15 for ThreeMonths/FifteenthDayOfMonth.
+func rruleFor(r accesslist.Recurrence) (string, error) {
+	interval := int(r.Frequency)
+	if interval <= 0 {
+		return "", trace.BadParameter("ical: invalid review frequency %v", r.Frequency)
+	}
+
+	day := int(r.DayOfMonth)
+	if day == 0 {
+		// A day-of-month of 0 means "last day of the month", which RRULE
+		// expresses as -1 rather than 0.
+		day = -1
+	}
+
+	return fmt.Sprintf("FREQ=MONTHLY;INTERVAL=%d;BYMONTHDAY=%d", interval, day), nil
+}
+
+// formatTime renders t in the UTC date-time form iCalendar expects
+// (YYYYMMDDTHHMMSSZ).
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+// escapeText escapes the characters iCalendar TEXT values must escape.
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
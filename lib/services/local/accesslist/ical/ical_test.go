@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ical
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/api/types/header"
+)
+
+func newTestAccessList(t *testing.T, name string, nextAuditDate time.Time) *accesslist.AccessList {
+	t.Helper()
+
+	list, err := accesslist.NewAccessList(
+		header.Metadata{Name: name},
+		accesslist.Spec{
+			Title:       "title-" + name,
+			Description: "test access list",
+			Owners: []accesslist.Owner{
+				{Name: "owner1"},
+				{Name: "owner2"},
+			},
+			Audit: accesslist.Audit{
+				NextAuditDate: nextAuditDate,
+				Recurrence: accesslist.Recurrence{
+					Frequency:  accesslist.ThreeMonths,
+					DayOfMonth: accesslist.FifteenthDayOfMonth,
+				},
+			},
+			Grants: accesslist.Grants{
+				Roles: []string{"role1", "role2"},
+			},
+		},
+	)
+	require.NoError(t, err)
+	return list
+}
+
+func TestRenderCalendarTodo(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	list := newTestAccessList(t, "accessList1", due)
+
+	cal, err := RenderCalendar([]*accesslist.AccessList{list}, nil, now)
+	require.NoError(t, err)
+
+	require.Contains(t, cal, "BEGIN:VCALENDAR")
+	require.Contains(t, cal, "BEGIN:VTODO")
+	require.Contains(t, cal, "UID:audit-accessList1@teleport")
+	require.Contains(t, cal, "DUE:20240315T000000Z")
+	require.Contains(t, cal, "RRULE:FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15")
+	require.Contains(t, cal, "CATEGORIES:role1,role2")
+	require.Contains(t, cal, "ATTENDEE;CN=owner1:mailto:owner1")
+	require.Contains(t, cal, "ATTENDEE;CN=owner2:mailto:owner2")
+	require.Contains(t, cal, "END:VTODO")
+	require.Contains(t, cal, "END:VCALENDAR")
+}
+
+func TestRenderCalendarExpiryEvent(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	list := newTestAccessList(t, "accessList1", due)
+
+	cal, err := RenderCalendar([]*accesslist.AccessList{list}, map[string][]MemberExpiry{
+		"accessList1": {{Member: "alice", Expires: expires}},
+	}, now)
+	require.NoError(t, err)
+
+	require.Contains(t, cal, "BEGIN:VEVENT")
+	require.Contains(t, cal, "UID:expiry-accessList1-alice@teleport")
+	require.Contains(t, cal, "DTSTART:20240201T000000Z")
+	require.Contains(t, cal, "END:VEVENT")
+}
+
+// TestRenderCalendarEscapesTitle guards against RFC 5545-invalid output:
+// a title containing TEXT-significant characters (comma, semicolon,
+// backslash) must come back escaped in both the audit VTODO and the
+// expiry VEVENT, just like owner.Name and exp.Member already do.
+func TestRenderCalendarEscapesTitle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	list := newTestAccessList(t, "accessList1", due)
+	list.Spec.Title = `Engineering, Prod; Access\Team`
+
+	cal, err := RenderCalendar([]*accesslist.AccessList{list}, map[string][]MemberExpiry{
+		"accessList1": {{Member: "alice", Expires: expires}},
+	}, now)
+	require.NoError(t, err)
+
+	require.NotContains(t, cal, `Engineering, Prod; Access\Team`)
+	require.Contains(t, cal, `Engineering\, Prod\; Access\\Team`)
+}
+
+func TestRRuleForLastDayOfMonth(t *testing.T) {
+	rrule, err := rruleFor(accesslist.Recurrence{
+		Frequency:  accesslist.OneMonth,
+		DayOfMonth: 0,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=-1", rrule)
+}
+
+func TestRRuleForInvalidFrequency(t *testing.T) {
+	_, err := rruleFor(accesslist.Recurrence{Frequency: 0})
+	require.Error(t, err)
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2023 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/lib/backend"
+)
+
+// resource is the subset of types.Resource that genericService needs in
+// order to store and retrieve an item by name.
+type resource interface {
+	GetName() string
+}
+
+// genericService is a small CRUD helper over a single backend key prefix,
+// shared by the access list resources (lists, members, reviews) so that
+// each one doesn't have to hand-roll marshaling, pagination, and not-found
+// handling separately.
+type genericService[T resource] struct {
+	backend       backend.Backend
+	backendPrefix string
+	marshalFunc   func(T) ([]byte, error)
+	unmarshalFunc func([]byte) (T, error)
+}
+
+func newGenericService[T resource](b backend.Backend, backendPrefix string, marshalFunc func(T) ([]byte, error), unmarshalFunc func([]byte) (T, error)) (*genericService[T], error) {
+	return &genericService[T]{
+		backend:       b,
+		backendPrefix: backendPrefix,
+		marshalFunc:   marshalFunc,
+		unmarshalFunc: unmarshalFunc,
+	}, nil
+}
+
+// MakeBackendItem marshals resource into a backend.Item stored under name.
+func (g *genericService[T]) MakeBackendItem(res T, name string) (backend.Item, error) {
+	data, err := g.marshalFunc(res)
+	if err != nil {
+		return backend.Item{}, trace.Wrap(err)
+	}
+	return backend.Item{
+		Key:   backend.Key(g.backendPrefix, name),
+		Value: data,
+	}, nil
+}
+
+func (g *genericService[T]) get(ctx context.Context, name string) (T, error) {
+	var zero T
+	item, err := g.backend.Get(ctx, backend.Key(g.backendPrefix, name))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return zero, trace.NotFound("%s %q doesn't exist", g.backendPrefix, name)
+		}
+		return zero, trace.Wrap(err)
+	}
+	res, err := g.unmarshalFunc(item.Value)
+	if err != nil {
+		return zero, trace.Wrap(err)
+	}
+	return res, nil
+}
+
+func (g *genericService[T]) upsert(ctx context.Context, res T) error {
+	return g.upsertKeyed(ctx, res.GetName(), res)
+}
+
+func (g *genericService[T]) upsertKeyed(ctx context.Context, key string, res T) error {
+	item, err := g.MakeBackendItem(res, key)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	lease, err := g.backend.Put(ctx, item)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	// Hand the stored revision back on res, so a later conditional update
+	// can be made against it.
+	if r, ok := any(res).(interface{ SetRevision(string) }); ok && lease != nil {
+		r.SetRevision(lease.Revision)
+	}
+	return nil
+}
+
+// conditionalUpdate writes res under name only if the stored item's
+// revision still matches expectedRevision, returning trace.CompareFailed
+// (with the current revision embedded for retry loops) otherwise.
+func (g *genericService[T]) conditionalUpdate(ctx context.Context, name string, res T, expectedRevision string) error {
+	item, err := g.MakeBackendItem(res, name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	item.Revision = expectedRevision
+
+	_, err = g.backend.ConditionalUpdate(ctx, item)
+	if trace.IsCompareFailed(err) {
+		return trace.Wrap(g.compareFailedWithCurrentRevision(ctx, item.Key, expectedRevision))
+	}
+	return trace.Wrap(err)
+}
+
+// conditionalDelete removes the item stored under key only if its
+// revision still matches expectedRevision.
+func (g *genericService[T]) conditionalDelete(ctx context.Context, key, expectedRevision string) error {
+	itemKey := backend.Key(g.backendPrefix, key)
+	err := g.backend.ConditionalDelete(ctx, itemKey, expectedRevision)
+	if trace.IsCompareFailed(err) {
+		return trace.Wrap(g.compareFailedWithCurrentRevision(ctx, itemKey, expectedRevision))
+	}
+	if trace.IsNotFound(err) {
+		return trace.NotFound("%s %q doesn't exist", g.backendPrefix, key)
+	}
+	return trace.Wrap(err)
+}
+
+// compareFailedWithCurrentRevision builds a trace.CompareFailed error
+// that embeds the item's actual current revision, so callers can decide
+// whether to retry with the fresh value.
+func (g *genericService[T]) compareFailedWithCurrentRevision(ctx context.Context, key []byte, expectedRevision string) error {
+	current, getErr := g.backend.Get(ctx, key)
+	if getErr != nil {
+		return trace.CompareFailed("revision expectation of %q not met", expectedRevision)
+	}
+	return trace.CompareFailed("revision expectation of %q not met (current revision is %q)", expectedRevision, current.Revision)
+}
+
+func (g *genericService[T]) delete(ctx context.Context, name string) error {
+	return g.deleteKeyed(ctx, name)
+}
+
+func (g *genericService[T]) deleteKeyed(ctx context.Context, key string) error {
+	err := g.backend.Delete(ctx, backend.Key(g.backendPrefix, key))
+	if trace.IsNotFound(err) {
+		return trace.NotFound("%s %q doesn't exist", g.backendPrefix, key)
+	}
+	return trace.Wrap(err)
+}
+
+func (g *genericService[T]) deleteAll(ctx context.Context) error {
+	startKey := backend.ExactKey(g.backendPrefix)
+	return trace.Wrap(g.backend.DeleteRange(ctx, startKey, backend.RangeEnd(startKey)))
+}
+
+func (g *genericService[T]) deleteAllWithPrefix(ctx context.Context, prefix string) error {
+	startKey := backend.ExactKey(g.backendPrefix, prefix)
+	return trace.Wrap(g.backend.DeleteRange(ctx, startKey, backend.RangeEnd(startKey)))
+}
+
+func (g *genericService[T]) getAll(ctx context.Context) ([]T, error) {
+	startKey := backend.ExactKey(g.backendPrefix)
+	result, err := g.backend.GetRange(ctx, startKey, backend.RangeEnd(startKey), 0)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	out := make([]T, 0, len(result.Items))
+	for _, item := range result.Items {
+		res, err := g.unmarshalFunc(item.Value)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+func (g *genericService[T]) list(ctx context.Context, pageSize int, nextToken string) ([]T, string, error) {
+	return g.listWithPrefixKey(ctx, backend.ExactKey(g.backendPrefix), pageSize, nextToken)
+}
+
+func (g *genericService[T]) listWithPrefix(ctx context.Context, prefix string, pageSize int, nextToken string) ([]T, string, error) {
+	return g.listWithPrefixKey(ctx, backend.ExactKey(g.backendPrefix, prefix), pageSize, nextToken)
+}
+
+func (g *genericService[T]) listWithPrefixKey(ctx context.Context, startKey []byte, pageSize int, nextToken string) ([]T, string, error) {
+	if pageSize <= 0 || pageSize > accessListMaxPageSize {
+		pageSize = accessListDefaultMaxPageLen
+	}
+
+	rangeStart := startKey
+	if nextToken != "" {
+		// nextToken is itself a full backend key captured from a previous
+		// page's result.Items (see below) -- joining it onto startKey again
+		// would double the prefix and break pagination past page one.
+		rangeStart = []byte(nextToken)
+	}
+
+	result, err := g.backend.GetRange(ctx, rangeStart, backend.RangeEnd(startKey), pageSize+1)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+
+	var out []T
+	var next string
+	for i, item := range result.Items {
+		if i == pageSize {
+			next = string(item.Key)
+			break
+		}
+		res, err := g.unmarshalFunc(item.Value)
+		if err != nil {
+			return nil, "", trace.Wrap(err)
+		}
+		out = append(out, res)
+	}
+
+	return out, next, nil
+}
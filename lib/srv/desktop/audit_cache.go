@@ -17,9 +17,20 @@ limitations under the License.
 package desktop
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gravitational/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"go.etcd.io/bbolt"
 )
 
 type directoryID uint32
@@ -34,36 +45,228 @@ type readRequestInfo struct {
 
 type writeRequestInfo readRequestInfo
 
-// maxAuditCacheItems is the maximum number of items we want
-// to allow in a single sharedDirectoryAuditCacheEntry.
-//
-// It's not a precise value, just one that should prevent the
-// cache from growing too large due to a misbehaving client.
-const maxAuditCacheItems = 2000
+// persistedRequestInfo mirrors readRequestInfo/writeRequestInfo with
+// exported fields, since encoding/json silently drops unexported ones.
+// It exists only to (de)serialize entries spilled to the on-disk
+// overflow store.
+type persistedRequestInfo struct {
+	DirectoryID directoryID
+	Path        string
+	Offset      uint64
+}
+
+func (info readRequestInfo) toPersisted() persistedRequestInfo {
+	return persistedRequestInfo{DirectoryID: info.directoryID, Path: info.path, Offset: info.offset}
+}
+
+func (p persistedRequestInfo) toRequestInfo() readRequestInfo {
+	return readRequestInfo{directoryID: p.DirectoryID, path: p.Path, offset: p.Offset}
+}
+
+// maxHotAuditCacheItems is the number of items the audit cache keeps
+// in memory before spilling further items to its on-disk overflow
+// store. This is sized to cover the common case (a session that opens
+// and reads/writes a modest number of files) without ever touching
+// disk.
+const maxHotAuditCacheItems = 2000
+
+// maxAuditCacheItems is the combined (in-memory + on-disk overflow)
+// ceiling past which the cache refuses further writes. It's not a
+// precise value, just one that should prevent a single session's audit
+// cache from growing without bound on disk due to a misbehaving or
+// malicious client.
+const maxAuditCacheItems = 100_000
+
+// auditCacheEntryTTL bounds how long a read or write request's
+// completion info is retained while waiting for the matching response
+// from the client. A client that never replies (or stalls indefinitely)
+// would otherwise pin an entry in the cache, and the disk overflow
+// store, forever. Variable (rather than const) so tests can shrink it.
+var auditCacheEntryTTL = 5 * time.Minute
+
+// auditCacheSweepInterval is how often the cache scans for entries
+// older than auditCacheEntryTTL. Variable so tests can shrink it.
+var auditCacheSweepInterval = time.Minute
+
+var (
+	auditCacheSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "teleport",
+		Subsystem: "desktop_access",
+		Name:      "shared_directory_audit_cache_size",
+		Help:      "Number of items currently held in the shared directory audit cache, by store.",
+	}, []string{"store"})
+
+	auditCacheEvictionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teleport",
+		Subsystem: "desktop_access",
+		Name:      "shared_directory_audit_cache_evictions_total",
+		Help:      "Number of shared directory audit cache entries evicted, by reason.",
+	}, []string{"reason"})
+
+	auditCacheMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "teleport",
+		Subsystem: "desktop_access",
+		Name:      "shared_directory_audit_cache_misses_total",
+		Help:      "Number of shared directory audit cache lookups that found no entry, by cache.",
+	}, []string{"cache"})
+)
+
+const (
+	auditCacheStoreHot  = "hot"
+	auditCacheStoreDisk = "disk"
+
+	auditCacheEvictionReasonTTL      = "ttl"
+	auditCacheEvictionReasonOverflow = "lru_overflow"
+)
 
-// totalItems returns the total number of items held in the cache.
-// The caller should hold a lock on the cache prior to calling this method.
-func (e *sharedDirectoryAuditCache) totalItems() int {
-	return len(e.nameCache) + len(e.readRequestCache) + len(e.writeRequestCache)
+var (
+	auditCacheNamesBucket  = []byte("names")
+	auditCacheReadsBucket  = []byte("reads")
+	auditCacheWritesBucket = []byte("writes")
+)
+
+// totalItems returns the total number of items held in the in-memory
+// portion of the cache. The caller should hold a lock on the cache
+// prior to calling this method.
+func (c *sharedDirectoryAuditCache) totalItems() int {
+	return len(c.nameCache) + len(c.readRequestCache) + len(c.writeRequestCache)
+}
+
+// diskItems returns the total number of items spilled to the on-disk
+// overflow store. The caller should hold a lock on the cache prior to
+// calling this method.
+func (c *sharedDirectoryAuditCache) diskItems() int {
+	return c.diskItemCount
 }
 
 // sharedDirectoryAuditCache is a data structure for caching information
 // from shared directory messages so that it can be used later for
 // creating shared directory audit events.
+//
+// Long-lived sessions that open many files can exceed what's reasonable
+// to keep resident in memory. Rather than terminate the session once
+// maxHotAuditCacheItems in-memory entries are reached, the cache spills
+// further entries to a small on-disk bbolt store (one file per
+// session), up to the much larger maxAuditCacheItems ceiling. Read and
+// write request entries, which are only ever held transiently while
+// awaiting a completion response, are also evicted on a TTL so that a
+// client that never responds can't pin entries — and disk space —
+// forever.
 type sharedDirectoryAuditCache struct {
 	sync.Mutex
 
 	nameCache         map[directoryID]directoryName
 	readRequestCache  map[completionID]readRequestInfo
 	writeRequestCache map[completionID]writeRequestInfo
+
+	// insertedAt tracks when each read/write request entry was
+	// inserted, keyed by "r:<cid>" or "w:<cid>", for TTL eviction.
+	insertedAt map[string]time.Time
+
+	diskPath      string
+	db            *bbolt.DB
+	diskItemCount int
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	wg        sync.WaitGroup
 }
 
-func newSharedDirectoryAuditCache() sharedDirectoryAuditCache {
-	return sharedDirectoryAuditCache{
+// newSharedDirectoryAuditCache returns a new, empty audit cache for the
+// session identified by sessionID. The on-disk overflow store, if ever
+// needed, is created lazily under dataDir and removed on Close.
+func newSharedDirectoryAuditCache(dataDir, sessionID string) sharedDirectoryAuditCache {
+	c := sharedDirectoryAuditCache{
 		nameCache:         make(map[directoryID]directoryName),
 		readRequestCache:  make(map[completionID]readRequestInfo),
 		writeRequestCache: make(map[completionID]writeRequestInfo),
+		insertedAt:        make(map[string]time.Time),
+		diskPath:          filepath.Join(dataDir, "audit-cache-"+sessionID+".db"),
+		closeCh:           make(chan struct{}),
 	}
+
+	c.wg.Add(1)
+	go c.sweepExpiredLoop()
+
+	return c
+}
+
+// Close stops the cache's background TTL sweeper and removes its
+// on-disk overflow store, if one was created.
+func (c *sharedDirectoryAuditCache) Close() error {
+	c.closeOnce.Do(func() { close(c.closeCh) })
+	c.wg.Wait()
+
+	c.Lock()
+	db := c.db
+	c.db = nil
+	c.Unlock()
+
+	if db == nil {
+		return nil
+	}
+	if err := db.Close(); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := os.Remove(c.diskPath); err != nil && !os.IsNotExist(err) {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+func (c *sharedDirectoryAuditCache) sweepExpiredLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(auditCacheSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-ticker.C:
+			c.sweepExpired()
+		}
+	}
+}
+
+func (c *sharedDirectoryAuditCache) sweepExpired() {
+	c.Lock()
+	defer c.Unlock()
+
+	cutoff := time.Now().Add(-auditCacheEntryTTL)
+	for key, t := range c.insertedAt {
+		if t.After(cutoff) {
+			continue
+		}
+		kind, rest := key[:1], key[1:]
+		cid, err := strconv.ParseUint(rest, 10, 32)
+		if err != nil {
+			// Shouldn't happen: insertedAt keys are only ever written by
+			// readRequestKey/writeRequestKey below.
+			delete(c.insertedAt, key)
+			continue
+		}
+		switch kind {
+		case "r":
+			if _, ok := c.readRequestCache[completionID(cid)]; ok {
+				delete(c.readRequestCache, completionID(cid))
+			} else {
+				c.deleteDiskEntryLocked(auditCacheReadsBucket, completionIDKey(completionID(cid)))
+			}
+		case "w":
+			if _, ok := c.writeRequestCache[completionID(cid)]; ok {
+				delete(c.writeRequestCache, completionID(cid))
+			} else {
+				c.deleteDiskEntryLocked(auditCacheWritesBucket, completionIDKey(completionID(cid)))
+			}
+		}
+		delete(c.insertedAt, key)
+		auditCacheEvictionsTotal.WithLabelValues(auditCacheEvictionReasonTTL).Inc()
+	}
+
+	auditCacheSize.WithLabelValues(auditCacheStoreHot).Set(float64(c.totalItems()))
+	auditCacheSize.WithLabelValues(auditCacheStoreDisk).Set(float64(c.diskItems()))
 }
 
 // SetName returns a non-nil error if the audit cache entry for sid exceeds its maximum size.
@@ -72,12 +275,13 @@ func (c *sharedDirectoryAuditCache) SetName(did directoryID, name directoryName)
 	c.Lock()
 	defer c.Unlock()
 
-	if c.totalItems() >= maxAuditCacheItems {
-		return trace.LimitExceeded("audit cache exceeded maximum size")
+	if c.totalItems() < maxHotAuditCacheItems {
+		c.nameCache[did] = name
+		auditCacheSize.WithLabelValues(auditCacheStoreHot).Set(float64(c.totalItems()))
+		return nil
 	}
 
-	c.nameCache[did] = name
-	return nil
+	return c.spillToDisk(auditCacheNamesBucket, directoryIDKey(did), []byte(name))
 }
 
 // SetReadRequestInfo returns a non-nil error if the audit cache exceeds its maximum size.
@@ -86,11 +290,21 @@ func (c *sharedDirectoryAuditCache) SetReadRequestInfo(cid completionID, info re
 	c.Lock()
 	defer c.Unlock()
 
-	if c.totalItems() >= maxAuditCacheItems {
-		return trace.LimitExceeded("audit cache exceeded maximum size")
+	if c.totalItems() < maxHotAuditCacheItems {
+		c.readRequestCache[cid] = info
+		c.insertedAt[readRequestKey(cid)] = time.Now()
+		auditCacheSize.WithLabelValues(auditCacheStoreHot).Set(float64(c.totalItems()))
+		return nil
 	}
 
-	c.readRequestCache[cid] = info
+	value, err := json.Marshal(info.toPersisted())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.spillToDisk(auditCacheReadsBucket, completionIDKey(cid), value); err != nil {
+		return err
+	}
+	c.insertedAt[readRequestKey(cid)] = time.Now()
 	return nil
 }
 
@@ -100,20 +314,96 @@ func (c *sharedDirectoryAuditCache) SetWriteRequestInfo(cid completionID, info w
 	c.Lock()
 	defer c.Unlock()
 
-	if c.totalItems() >= maxAuditCacheItems {
+	if c.totalItems() < maxHotAuditCacheItems {
+		c.writeRequestCache[cid] = info
+		c.insertedAt[writeRequestKey(cid)] = time.Now()
+		auditCacheSize.WithLabelValues(auditCacheStoreHot).Set(float64(c.totalItems()))
+		return nil
+	}
+
+	value, err := json.Marshal(readRequestInfo(info).toPersisted())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := c.spillToDisk(auditCacheWritesBucket, completionIDKey(cid), value); err != nil {
+		return err
+	}
+	c.insertedAt[writeRequestKey(cid)] = time.Now()
+	return nil
+}
+
+// spillToDisk writes key/value to bucket in the on-disk overflow store,
+// lazily creating the store on first use. The caller must hold c's lock.
+func (c *sharedDirectoryAuditCache) spillToDisk(bucket, key, value []byte) error {
+	if c.totalItems()+c.diskItemCount >= maxAuditCacheItems {
 		return trace.LimitExceeded("audit cache exceeded maximum size")
 	}
 
-	c.writeRequestCache[cid] = info
+	db, err := c.openDiskStoreLocked()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	c.diskItemCount++
+	auditCacheSize.WithLabelValues(auditCacheStoreDisk).Set(float64(c.diskItemCount))
+	auditCacheEvictionsTotal.WithLabelValues(auditCacheEvictionReasonOverflow).Inc()
 	return nil
 }
 
+func (c *sharedDirectoryAuditCache) openDiskStoreLocked() (*bbolt.DB, error) {
+	if c.db != nil {
+		return c.db, nil
+	}
+
+	db, err := bbolt.Open(c.diskPath, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	c.db = db
+	log.Debugf("Shared directory audit cache overflowed to disk at %v.", c.diskPath)
+	return db, nil
+}
+
 func (c *sharedDirectoryAuditCache) GetName(did directoryID) (name directoryName, ok bool) {
 	c.Lock()
 	defer c.Unlock()
 
-	name, ok = c.nameCache[did]
-	return
+	if name, ok = c.nameCache[did]; ok {
+		return name, true
+	}
+
+	if c.db == nil {
+		auditCacheMissesTotal.WithLabelValues("name").Inc()
+		return "", false
+	}
+
+	var value []byte
+	_ = c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(auditCacheNamesBucket)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(directoryIDKey(did)); v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if value == nil {
+		auditCacheMissesTotal.WithLabelValues("name").Inc()
+		return "", false
+	}
+	return directoryName(value), true
 }
 
 // TakeReadRequestInfo gets the readRequestInfo for completion ID cid,
@@ -122,11 +412,18 @@ func (c *sharedDirectoryAuditCache) TakeReadRequestInfo(cid completionID) (info
 	c.Lock()
 	defer c.Unlock()
 
-	info, ok = c.readRequestCache[cid]
-	if ok {
+	if info, ok = c.readRequestCache[cid]; ok {
 		delete(c.readRequestCache, cid)
+		delete(c.insertedAt, readRequestKey(cid))
+		return info, true
 	}
-	return
+
+	if info, ok = c.takeFromDiskLocked(auditCacheReadsBucket, completionIDKey(cid)); ok {
+		return info, true
+	}
+
+	auditCacheMissesTotal.WithLabelValues("read").Inc()
+	return readRequestInfo{}, false
 }
 
 // TakeWriteRequestInfo gets the writeRequestInfo for completion ID cid,
@@ -135,9 +432,105 @@ func (c *sharedDirectoryAuditCache) TakeWriteRequestInfo(cid completionID) (info
 	c.Lock()
 	defer c.Unlock()
 
-	info, ok = c.writeRequestCache[cid]
-	if ok {
+	if info, ok = c.writeRequestCache[cid]; ok {
 		delete(c.writeRequestCache, cid)
+		delete(c.insertedAt, writeRequestKey(cid))
+		return info, true
 	}
-	return
+
+	read, ok := c.takeFromDiskLocked(auditCacheWritesBucket, completionIDKey(cid))
+	if !ok {
+		auditCacheMissesTotal.WithLabelValues("write").Inc()
+		return writeRequestInfo{}, false
+	}
+	return writeRequestInfo(read), true
+}
+
+// takeFromDiskLocked looks up key in bucket in the on-disk overflow
+// store, deleting it in the process. The caller must hold c's lock.
+func (c *sharedDirectoryAuditCache) takeFromDiskLocked(bucket, key []byte) (info readRequestInfo, ok bool) {
+	if c.db == nil {
+		return readRequestInfo{}, false
+	}
+
+	var value []byte
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(key); v != nil {
+			value = append([]byte(nil), v...)
+			return b.Delete(key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Failed to read shared directory audit cache overflow store: %v", err)
+		return readRequestInfo{}, false
+	}
+	if value == nil {
+		return readRequestInfo{}, false
+	}
+	var persisted persistedRequestInfo
+	if err := json.Unmarshal(value, &persisted); err != nil {
+		log.Warnf("Failed to unmarshal shared directory audit cache overflow entry: %v", err)
+		return readRequestInfo{}, false
+	}
+	info = persisted.toRequestInfo()
+
+	c.diskItemCount--
+	auditCacheSize.WithLabelValues(auditCacheStoreDisk).Set(float64(c.diskItemCount))
+	return info, true
+}
+
+// deleteDiskEntryLocked removes key from bucket in the on-disk overflow
+// store, if an entry for it exists there. The caller must hold c's lock.
+func (c *sharedDirectoryAuditCache) deleteDiskEntryLocked(bucket, key []byte) {
+	if c.db == nil {
+		return
+	}
+
+	var existed bool
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		if b.Get(key) != nil {
+			existed = true
+			return b.Delete(key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Failed to evict expired shared directory audit cache overflow entry: %v", err)
+		return
+	}
+	if !existed {
+		return
+	}
+
+	c.diskItemCount--
+	auditCacheSize.WithLabelValues(auditCacheStoreDisk).Set(float64(c.diskItemCount))
+}
+
+func directoryIDKey(did directoryID) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(did))
+	return key
+}
+
+func completionIDKey(cid completionID) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(cid))
+	return key
+}
+
+func readRequestKey(cid completionID) string {
+	return fmt.Sprintf("r%d", cid)
+}
+
+func writeRequestKey(cid completionID) string {
+	return fmt.Sprintf("w%d", cid)
 }
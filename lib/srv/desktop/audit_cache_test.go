@@ -0,0 +1,126 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package desktop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedDirectoryAuditCacheHotPath(t *testing.T) {
+	c := newSharedDirectoryAuditCache(t.TempDir(), "test-session")
+	defer c.Close()
+
+	require.NoError(t, c.SetName(1, "docs"))
+	name, ok := c.GetName(1)
+	require.True(t, ok)
+	require.Equal(t, directoryName("docs"), name)
+
+	require.NoError(t, c.SetReadRequestInfo(1, readRequestInfo{directoryID: 1, path: "a.txt", offset: 10}))
+	info, ok := c.TakeReadRequestInfo(1)
+	require.True(t, ok)
+	require.Equal(t, readRequestInfo{directoryID: 1, path: "a.txt", offset: 10}, info)
+
+	// Taking again should miss, since TakeReadRequestInfo removes the entry.
+	_, ok = c.TakeReadRequestInfo(1)
+	require.False(t, ok)
+}
+
+func TestSharedDirectoryAuditCacheOverflowsToDisk(t *testing.T) {
+	c := newSharedDirectoryAuditCache(t.TempDir(), "overflow-session")
+	defer c.Close()
+
+	for i := 0; i < maxHotAuditCacheItems; i++ {
+		require.NoError(t, c.SetName(directoryID(i), directoryName("dir")))
+	}
+	require.Nil(t, c.db, "cache shouldn't have spilled to disk yet")
+
+	require.NoError(t, c.SetReadRequestInfo(1, readRequestInfo{directoryID: 1, path: "overflow.txt", offset: 42}))
+	require.NotNil(t, c.db, "cache should have spilled the overflowing entry to disk")
+
+	info, ok := c.TakeReadRequestInfo(1)
+	require.True(t, ok)
+	require.Equal(t, readRequestInfo{directoryID: 1, path: "overflow.txt", offset: 42}, info)
+
+	// The overflow entry was consumed, so it shouldn't still be on disk.
+	_, ok = c.TakeReadRequestInfo(1)
+	require.False(t, ok)
+}
+
+func TestSharedDirectoryAuditCacheRejectsBeyondMaxSize(t *testing.T) {
+	c := newSharedDirectoryAuditCache(t.TempDir(), "full-session")
+	defer c.Close()
+	c.diskItemCount = maxAuditCacheItems - maxHotAuditCacheItems
+
+	for i := 0; i < maxHotAuditCacheItems; i++ {
+		require.NoError(t, c.SetName(directoryID(i), directoryName("dir")))
+	}
+
+	err := c.SetReadRequestInfo(999, readRequestInfo{directoryID: 999, path: "a.txt"})
+	require.Error(t, err)
+}
+
+func TestSharedDirectoryAuditCacheEvictsExpiredEntries(t *testing.T) {
+	originalTTL, originalSweep := auditCacheEntryTTL, auditCacheSweepInterval
+	auditCacheEntryTTL = 10 * time.Millisecond
+	auditCacheSweepInterval = 5 * time.Millisecond
+	defer func() {
+		auditCacheEntryTTL, auditCacheSweepInterval = originalTTL, originalSweep
+	}()
+
+	c := newSharedDirectoryAuditCache(t.TempDir(), "ttl-session")
+	defer c.Close()
+
+	require.NoError(t, c.SetReadRequestInfo(1, readRequestInfo{directoryID: 1, path: "a.txt"}))
+
+	require.Eventually(t, func() bool {
+		c.Lock()
+		defer c.Unlock()
+		_, ok := c.readRequestCache[1]
+		return !ok
+	}, time.Second, 5*time.Millisecond, "expired read request info should have been swept")
+}
+
+func TestSharedDirectoryAuditCacheEvictsExpiredOverflowEntries(t *testing.T) {
+	originalTTL, originalSweep := auditCacheEntryTTL, auditCacheSweepInterval
+	auditCacheEntryTTL = 10 * time.Millisecond
+	auditCacheSweepInterval = 5 * time.Millisecond
+	defer func() {
+		auditCacheEntryTTL, auditCacheSweepInterval = originalTTL, originalSweep
+	}()
+
+	c := newSharedDirectoryAuditCache(t.TempDir(), "ttl-overflow-session")
+	defer c.Close()
+
+	for i := 0; i < maxHotAuditCacheItems; i++ {
+		require.NoError(t, c.SetName(directoryID(i), directoryName("dir")))
+	}
+	require.NoError(t, c.SetReadRequestInfo(1, readRequestInfo{directoryID: 1, path: "overflow.txt"}))
+	require.NotNil(t, c.db, "entry should have spilled to disk")
+
+	require.Eventually(t, func() bool {
+		c.Lock()
+		n := c.diskItemCount
+		c.Unlock()
+		return n == 0
+	}, time.Second, 5*time.Millisecond, "expired overflow entry should have been swept from disk")
+
+	_, ok := c.TakeReadRequestInfo(1)
+	require.False(t, ok, "swept overflow entry shouldn't still be retrievable")
+}
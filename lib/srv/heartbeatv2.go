@@ -27,7 +27,6 @@ import (
 	"github.com/gravitational/teleport/api/client/proto"
 	apidefaults "github.com/gravitational/teleport/api/defaults"
 	"github.com/gravitational/teleport/api/types"
-	"github.com/gravitational/teleport/api/utils/retryutils"
 	"github.com/gravitational/teleport/lib/auth"
 	"github.com/gravitational/teleport/lib/defaults"
 	"github.com/gravitational/teleport/lib/inventory"
@@ -57,6 +56,20 @@ type SSHServerHeartbeatConfig struct {
 	AnnounceInterval time.Duration
 	// PollInterval is the interval at which checks for change are performed (optional).
 	PollInterval time.Duration
+	// BackoffStrategy paces fallback announce retries and announce/poll
+	// jitter (optional, defaults to NewSeventhJitterBackoff).
+	BackoffStrategy BackoffStrategy
+	// TokenAcquirer gates fallback announces behind a fleet-wide rate
+	// limit, so that many agents losing their control stream at once
+	// don't hammer auth with fallback upserts (optional, defaults to
+	// unlimited).
+	TokenAcquirer HeartbeatTokenAcquirer
+	// HealthReporter receives structured health state transitions as the
+	// heartbeat's connectivity to auth changes (optional).
+	HealthReporter HealthReporter
+	// TransportConfig caps the size of outgoing InventoryHeartbeat
+	// messages (optional, defaults to DefaultInventoryMaxMessageSize).
+	TransportConfig InventoryTransportConfig
 }
 
 func (c *SSHServerHeartbeatConfig) Check() error {
@@ -66,6 +79,9 @@ func (c *SSHServerHeartbeatConfig) Check() error {
 	if c.GetServer == nil {
 		return trace.BadParameter("missing required parameter GetServer for ssh heartbeat")
 	}
+	if err := c.TransportConfig.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
 	return nil
 }
 
@@ -76,8 +92,9 @@ func NewSSHServerHeartbeat(cfg SSHServerHeartbeatConfig) (*HeartbeatV2, error) {
 
 	var metadataPtr atomic.Pointer[metadata.Metadata]
 	inner := &sshServerHeartbeatV2{
-		getMetadata: metadata.Get,
-		announcer:   cfg.Announcer,
+		getMetadata:    metadata.Get,
+		announcer:      cfg.Announcer,
+		maxMessageSize: cfg.TransportConfig.MaxMessageSize,
 	}
 	inner.getServer = func(ctx context.Context) *types.ServerV2 {
 		server := cfg.GetServer()
@@ -108,6 +125,9 @@ func NewSSHServerHeartbeat(cfg SSHServerHeartbeatConfig) (*HeartbeatV2, error) {
 		onHeartbeatInner: cfg.OnHeartbeat,
 		announceInterval: cfg.AnnounceInterval,
 		pollInterval:     cfg.PollInterval,
+		backoffStrategy:  cfg.BackoffStrategy,
+		tokenAcquirer:    cfg.TokenAcquirer,
+		healthReporter:   cfg.HealthReporter,
 	}), nil
 }
 
@@ -183,6 +203,11 @@ type HeartbeatV2 struct {
 	// based heartbeats inherit backoff from the stream handle and don't need special backoff.
 	fallbackBackoffTime time.Time
 
+	// fallbackFailures counts consecutive fallback announce failures
+	// since the last success, for strategies (e.g. exponential backoff)
+	// whose next-attempt delay depends on the failure count.
+	fallbackFailures int
+
 	// shouldAnnounce is set to true if announce interval elapses, or if polling informs us of a change.
 	// it stays true until a *successful* announce. the value of this variable is preserved when going
 	// between the inner control stream based announce loop and the outer upsert based announce loop.
@@ -192,12 +217,22 @@ type HeartbeatV2 struct {
 
 	// announceWaiters are used in tests to wait for an announce operation to occur
 	announceWaiters []chan struct{}
+
+	// lastAnnounceSuccess and lastFailure/lastErr track the timestamps
+	// published to healthReporter, so that each report carries the full
+	// history rather than just the latest transition.
+	lastAnnounceSuccess time.Time
+	lastFailure         time.Time
+	lastErr             error
 }
 
 type heartbeatV2Config struct {
 	announceInterval time.Duration
 	pollInterval     time.Duration
 	onHeartbeatInner func(error)
+	backoffStrategy  BackoffStrategy
+	tokenAcquirer    HeartbeatTokenAcquirer
+	healthReporter   HealthReporter
 
 	// -- below values only used in tests
 
@@ -226,12 +261,63 @@ func (c *heartbeatV2Config) SetDefaults() {
 		// quickly teleport detects that it is in a degraded state.
 		c.degradedCheckInterval = apidefaults.ServerKeepAliveTTL()
 	}
+
+	if c.backoffStrategy == nil {
+		c.backoffStrategy = NewSeventhJitterBackoff(c.fallbackBackoff, c.degradedCheckInterval)
+	}
 }
 
 // noSenderErr is used to periodically trigger "degraded state" events when the control
 // stream has no sender available.
 var noSenderErr = trace.Errorf("no control stream sender available")
 
+// acquireFallbackToken reports whether a fallback announce attempt may
+// proceed right now. When no TokenAcquirer is configured, every attempt
+// is granted (today's behavior). When one is configured and declines to
+// grant a token — e.g. because the fleet-wide fallback announce budget
+// is exhausted after many agents lost their control stream at once —
+// or fails, this counts a fallback failure, schedules the next retry via
+// backoffStrategy and returns false. The caller then stays in the
+// fallback backoff state without hitting auth and without reporting a
+// heartbeat error.
+func (h *HeartbeatV2) acquireFallbackToken() bool {
+	if h.tokenAcquirer == nil {
+		return true
+	}
+
+	granted, err := h.tokenAcquirer.AcquireFallbackToken(h.closeContext, h.inner.ResourceKind())
+	if err != nil {
+		log.Warnf("Failed to acquire fallback heartbeat token: %v", err)
+	}
+	if err != nil || !granted {
+		h.fallbackFailures++
+		h.fallbackBackoffTime = h.backoffStrategy.NextFallbackAttempt(time.Now(), h.fallbackFailures)
+		return false
+	}
+	return true
+}
+
+// reportHealth records a health state transition and, if a HealthReporter
+// is configured, publishes it.
+func (h *HeartbeatV2) reportHealth(state HealthState, err error) {
+	if err != nil {
+		h.lastFailure = time.Now()
+		h.lastErr = err
+	} else if state == HealthStateHealthy {
+		h.lastAnnounceSuccess = time.Now()
+	}
+
+	if h.healthReporter == nil {
+		return
+	}
+	h.healthReporter.ReportHealth(HealthStatus{
+		State:               state,
+		LastAnnounceSuccess: h.lastAnnounceSuccess,
+		LastFailure:         h.lastFailure,
+		LastError:           h.lastErr,
+	})
+}
+
 func (h *HeartbeatV2) run() {
 	// note: these errors are never actually displayed, but onHeartbeat expects an error,
 	// so we just allocate something reasonably descriptive once.
@@ -243,7 +329,7 @@ func (h *HeartbeatV2) run() {
 	h.announce = interval.New(interval.Config{
 		FirstDuration: utils.HalfJitter(h.announceInterval),
 		Duration:      h.announceInterval,
-		Jitter:        retryutils.NewSeventhJitter(),
+		Jitter:        h.backoffStrategy.AnnounceJitter(),
 	})
 	defer h.announce.Stop()
 
@@ -251,7 +337,7 @@ func (h *HeartbeatV2) run() {
 	h.poll = interval.New(interval.Config{
 		FirstDuration: utils.HalfJitter(h.pollInterval),
 		Duration:      h.pollInterval,
-		Jitter:        retryutils.NewSeventhJitter(),
+		Jitter:        h.backoffStrategy.AnnounceJitter(),
 	})
 	defer h.poll.Stop()
 
@@ -268,6 +354,7 @@ func (h *HeartbeatV2) run() {
 
 	h.testEvent(hbv2Start)
 	defer h.testEvent(hbv2Close)
+	defer h.reportHealth(HealthStateClosed, nil)
 
 	for {
 		// outer loop performs announcement via the fallback method (used for backwards compatibility
@@ -275,13 +362,24 @@ func (h *HeartbeatV2) run() {
 
 		if h.shouldAnnounce {
 			if h.inner.SupportsFallback() {
-				if time.Now().After(h.fallbackBackoffTime) {
+				if !time.Now().After(h.fallbackBackoffTime) {
+					h.testEvent(hbv2FallbackBackoff)
+					h.reportHealth(HealthStateFallbackBackoff, nil)
+				} else if !h.acquireFallbackToken() {
+					// no token available within the current backoff window; treat this
+					// as a soft failure and wait for the jittered retry that
+					// acquireFallbackToken has already scheduled.
+					h.testEvent(hbv2FallbackBackoff)
+					h.reportHealth(HealthStateFallbackBackoff, nil)
+				} else {
+					h.reportHealth(HealthStateAnnouncing, nil)
 					if ok := h.inner.FallbackAnnounce(h.closeContext); ok {
 						h.testEvent(hbv2FallbackOk)
 						// reset announce interval and state on successful announce
 						h.announce.Reset()
 						h.degradedCheck.Reset()
 						h.shouldAnnounce = false
+						h.fallbackFailures = 0
 						h.onHeartbeat(nil)
 
 						// unblock tests waiting on an announce operation
@@ -292,11 +390,10 @@ func (h *HeartbeatV2) run() {
 					} else {
 						h.testEvent(hbv2FallbackErr)
 						// announce failed, enter a backoff state.
-						h.fallbackBackoffTime = time.Now().Add(utils.SeventhJitter(h.fallbackBackoff))
+						h.fallbackFailures++
+						h.fallbackBackoffTime = h.backoffStrategy.NextFallbackAttempt(time.Now(), h.fallbackFailures)
 						h.onHeartbeat(h.fallbackFailed)
 					}
-				} else {
-					h.testEvent(hbv2FallbackBackoff)
 				}
 			} else {
 				h.testEvent(hbv2NoFallback)
@@ -345,6 +442,7 @@ func (h *HeartbeatV2) runWithSender(sender inventory.DownstreamSender) {
 
 	for {
 		if h.shouldAnnounce {
+			h.reportHealth(HealthStateAnnouncing, nil)
 			if ok := h.inner.Announce(h.closeContext, sender); ok {
 				h.testEvent(hbv2AnnounceOk)
 				// reset announce interval and state on successful announce
@@ -433,8 +531,10 @@ func (h *HeartbeatV2) ForceSend(timeout time.Duration) error {
 func (h *HeartbeatV2) onHeartbeat(err error) {
 	if err != nil {
 		h.testEvent(hbv2OnHeartbeatErr)
+		h.reportHealth(HealthStateDegraded, err)
 	} else {
 		h.testEvent(hbv2OnHeartbeatOk)
+		h.reportHealth(HealthStateHealthy, nil)
 	}
 	if h.onHeartbeatInner == nil {
 		return
@@ -457,6 +557,10 @@ type heartbeatV2Driver interface {
 	Announce(ctx context.Context, sender inventory.DownstreamSender) (ok bool)
 	// SupportsFallback checks if the driver supports fallback.
 	SupportsFallback() bool
+	// ResourceKind identifies the kind of resource this driver heartbeats,
+	// used as the key when rate-limiting fallback announces via a
+	// HeartbeatTokenAcquirer.
+	ResourceKind() string
 }
 
 type metadataGetter func(ctx context.Context) (*metadata.Metadata, error)
@@ -467,6 +571,10 @@ type sshServerHeartbeatV2 struct {
 	getMetadata metadataGetter
 	announcer   auth.Announcer
 	prev        *types.ServerV2
+
+	// maxMessageSize caps the encoded size of an outgoing
+	// InventoryHeartbeat; see InventoryTransportConfig.MaxMessageSize.
+	maxMessageSize int
 }
 
 func (h *sshServerHeartbeatV2) Poll(ctx context.Context) (changed bool) {
@@ -480,6 +588,10 @@ func (h *sshServerHeartbeatV2) SupportsFallback() bool {
 	return h.announcer != nil
 }
 
+func (h *sshServerHeartbeatV2) ResourceKind() string {
+	return "node"
+}
+
 func (h *sshServerHeartbeatV2) FallbackAnnounce(ctx context.Context) (ok bool) {
 	if h.announcer == nil {
 		return false
@@ -496,10 +608,14 @@ func (h *sshServerHeartbeatV2) FallbackAnnounce(ctx context.Context) (ok bool) {
 
 func (h *sshServerHeartbeatV2) Announce(ctx context.Context, sender inventory.DownstreamSender) (ok bool) {
 	server := h.getServer(ctx)
-	err := sender.Send(ctx, proto.InventoryHeartbeat{
-		SSHServer: h.getServer(ctx),
-	})
-	if err != nil {
+	hb := proto.InventoryHeartbeat{
+		SSHServer: server,
+	}
+	if !announceSizeOk(hb, h.maxMessageSize) {
+		log.Warnf("Dropping ssh server heartbeat: encoded size exceeds configured max message size %d", h.maxMessageSize)
+		return false
+	}
+	if err := sender.Send(ctx, hb); err != nil {
 		log.Warnf("Failed to perform inventory heartbeat for ssh server: %v", err)
 		return false
 	}
@@ -0,0 +1,183 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gravitational/teleport/api/utils/retryutils"
+	"github.com/gravitational/teleport/lib/utils"
+)
+
+// BackoffStrategy decides how HeartbeatV2 paces its fallback announce
+// retries and its announce/poll interval jitter. It also answers whether
+// a gap since the last successful heartbeat constitutes a degraded
+// state, but HeartbeatV2 itself does not call ShouldDegrade: its
+// degraded-state signal comes from its degraded check interval. The
+// default (used when SSHServerHeartbeatConfig.BackoffStrategy is unset)
+// replicates the historical behavior of a flat seventh-jitter backoff;
+// the built-in alternatives below let operators avoid synchronizing
+// thundering herds of re-announces across a fleet when auth is flapping.
+type BackoffStrategy interface {
+	// NextFallbackAttempt returns the time at which the next fallback
+	// announce attempt should be made, given that the previous
+	// failures (>= 1) consecutive fallback attempts since the last
+	// success have failed.
+	NextFallbackAttempt(now time.Time, failures int) time.Time
+	// AnnounceJitter returns the jitter applied to the announce and
+	// poll interval durations.
+	AnnounceJitter() retryutils.Jitter
+	// ShouldDegrade reports whether, given sinceLastSuccess (the time
+	// elapsed since the last successful announce or fallback), the
+	// caller should consider itself in a degraded state.
+	ShouldDegrade(sinceLastSuccess time.Duration) bool
+}
+
+// seventhJitterBackoff is the default BackoffStrategy, preserving
+// HeartbeatV2's historical single seventh-jitter approach.
+type seventhJitterBackoff struct {
+	base         time.Duration
+	degradeAfter time.Duration
+}
+
+// NewSeventhJitterBackoff returns the historical HeartbeatV2 backoff
+// strategy: a flat base duration softened by a "seventh jitter" (a
+// random reduction of up to 1/7th), for both fallback retries and
+// announce/poll intervals.
+func NewSeventhJitterBackoff(base, degradeAfter time.Duration) BackoffStrategy {
+	return &seventhJitterBackoff{base: base, degradeAfter: degradeAfter}
+}
+
+func (s *seventhJitterBackoff) NextFallbackAttempt(now time.Time, _ int) time.Time {
+	return now.Add(utils.SeventhJitter(s.base))
+}
+
+func (s *seventhJitterBackoff) AnnounceJitter() retryutils.Jitter {
+	return retryutils.NewSeventhJitter()
+}
+
+func (s *seventhJitterBackoff) ShouldDegrade(sinceLastSuccess time.Duration) bool {
+	return sinceLastSuccess > s.degradeAfter
+}
+
+// constantBackoff retries at a fixed interval, jittered to avoid
+// synchronizing retries across a fleet that failed at the same moment.
+type constantBackoff struct {
+	interval     time.Duration
+	degradeAfter time.Duration
+}
+
+// NewConstantBackoff returns a BackoffStrategy that retries fallback
+// announces at a fixed, jittered interval.
+func NewConstantBackoff(interval, degradeAfter time.Duration) BackoffStrategy {
+	return &constantBackoff{interval: interval, degradeAfter: degradeAfter}
+}
+
+func (c *constantBackoff) NextFallbackAttempt(now time.Time, _ int) time.Time {
+	return now.Add(utils.SeventhJitter(c.interval))
+}
+
+func (c *constantBackoff) AnnounceJitter() retryutils.Jitter {
+	return retryutils.NewSeventhJitter()
+}
+
+func (c *constantBackoff) ShouldDegrade(sinceLastSuccess time.Duration) bool {
+	return sinceLastSuccess > c.degradeAfter
+}
+
+// exponentialBackoff doubles its retry interval on every consecutive
+// failure, up to cap, then jitters the result.
+type exponentialBackoff struct {
+	base         time.Duration
+	cap          time.Duration
+	degradeAfter time.Duration
+}
+
+// NewExponentialBackoff returns a BackoffStrategy that doubles its retry
+// interval with every consecutive fallback failure, capped at cap.
+func NewExponentialBackoff(base, cap, degradeAfter time.Duration) BackoffStrategy {
+	return &exponentialBackoff{base: base, cap: cap, degradeAfter: degradeAfter}
+}
+
+func (e *exponentialBackoff) NextFallbackAttempt(now time.Time, failures int) time.Time {
+	if failures < 1 {
+		failures = 1
+	}
+	dur := e.base
+	for i := 1; i < failures && dur < e.cap; i++ {
+		dur *= 2
+	}
+	if dur > e.cap {
+		dur = e.cap
+	}
+	return now.Add(utils.SeventhJitter(dur))
+}
+
+func (e *exponentialBackoff) AnnounceJitter() retryutils.Jitter {
+	return retryutils.NewSeventhJitter()
+}
+
+func (e *exponentialBackoff) ShouldDegrade(sinceLastSuccess time.Duration) bool {
+	return sinceLastSuccess > e.degradeAfter
+}
+
+// decorrelatedJitterBackoff implements the "decorrelated jitter"
+// algorithm (sleep = min(cap, random_between(base, prev*3))), which
+// spreads retries out more evenly than a plain exponential backoff
+// across a large fleet that all failed around the same time.
+type decorrelatedJitterBackoff struct {
+	base         time.Duration
+	cap          time.Duration
+	degradeAfter time.Duration
+
+	mu   sync.Mutex
+	prev time.Duration
+}
+
+// NewDecorrelatedJitterBackoff returns a BackoffStrategy implementing
+// the decorrelated-jitter algorithm popularized by the AWS Architecture
+// Blog's backoff-and-jitter post, capped at cap.
+func NewDecorrelatedJitterBackoff(base, cap, degradeAfter time.Duration) BackoffStrategy {
+	return &decorrelatedJitterBackoff{base: base, cap: cap, degradeAfter: degradeAfter, prev: base}
+}
+
+func (d *decorrelatedJitterBackoff) NextFallbackAttempt(now time.Time, _ int) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	upper := d.prev * 3
+	if upper <= d.base {
+		upper = d.base + 1
+	}
+	next := d.base + time.Duration(rand.Int63n(int64(upper-d.base)))
+	if next > d.cap {
+		next = d.cap
+	}
+	d.prev = next
+
+	return now.Add(next)
+}
+
+func (d *decorrelatedJitterBackoff) AnnounceJitter() retryutils.Jitter {
+	return retryutils.NewSeventhJitter()
+}
+
+func (d *decorrelatedJitterBackoff) ShouldDegrade(sinceLastSuccess time.Duration) bool {
+	return sinceLastSuccess > d.degradeAfter
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeventhJitterBackoffShouldDegrade(t *testing.T) {
+	b := NewSeventhJitterBackoff(time.Minute, 5*time.Minute)
+	require.False(t, b.ShouldDegrade(time.Minute))
+	require.True(t, b.ShouldDegrade(6*time.Minute))
+}
+
+func TestConstantBackoffStaysWithinJitterBounds(t *testing.T) {
+	b := NewConstantBackoff(time.Minute, time.Minute)
+	now := time.Now()
+	next := b.NextFallbackAttempt(now, 1)
+	require.True(t, next.After(now))
+	require.LessOrEqual(t, next.Sub(now), time.Minute)
+}
+
+func TestExponentialBackoffGrowsAndCaps(t *testing.T) {
+	b := NewExponentialBackoff(time.Second, 10*time.Second, time.Minute)
+	now := time.Now()
+
+	first := b.NextFallbackAttempt(now, 1).Sub(now)
+	third := b.NextFallbackAttempt(now, 3).Sub(now)
+	require.Less(t, first, third)
+
+	capped := b.NextFallbackAttempt(now, 100).Sub(now)
+	require.LessOrEqual(t, capped, 10*time.Second)
+}
+
+func TestDecorrelatedJitterBackoffRespectsCap(t *testing.T) {
+	b := NewDecorrelatedJitterBackoff(time.Second, 5*time.Second, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 20; i++ {
+		next := b.NextFallbackAttempt(now, i+1).Sub(now)
+		require.GreaterOrEqual(t, next, time.Second)
+		require.LessOrEqual(t, next, 5*time.Second)
+	}
+}
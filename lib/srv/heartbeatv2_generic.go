@@ -0,0 +1,411 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/inventory"
+	"github.com/gravitational/teleport/lib/inventory/metadata"
+)
+
+// genericHeartbeatV2Config configures a resourceHeartbeatV2 for a single
+// resource kind T. NewAppServerHeartbeat, NewDatabaseServerHeartbeat,
+// NewKubernetesServerHeartbeat and NewWindowsDesktopServiceHeartbeat each
+// build one of these and wrap it in a HeartbeatV2, so the inventory
+// control stream wiring, degraded-state logic, cloud metadata injection
+// and fallback announcer path only need to be implemented once rather
+// than once per resource kind.
+//
+// sshServerHeartbeatV2 predates resourceHeartbeatV2 and still hand-rolls
+// this logic; migrating it to share this implementation is a reasonable
+// follow-up but out of scope here, since it's exercised by the fallback
+// path already relied upon for v9 auth back-compat.
+type genericHeartbeatV2Config[T any] struct {
+	// GetResource returns the latest value of the resource to heartbeat.
+	GetResource func() T
+	// SetCloudMetadata attaches previously-discovered cloud metadata to
+	// resource, if the resource kind supports it (optional).
+	SetCloudMetadata func(resource T, meta *metadata.CloudMetadata)
+	// Compare reports whether a and b are equal for change-detection
+	// purposes (optional, defaults to reflect.DeepEqual).
+	Compare func(a, b T) bool
+	// ToHeartbeat builds the InventoryHeartbeat payload carrying resource.
+	ToHeartbeat func(resource T) proto.InventoryHeartbeat
+	// FallbackUpsert performs the fallback (pre-inventory-control-stream)
+	// announce for resource (optional; a nil value means the driver
+	// doesn't support fallback).
+	FallbackUpsert func(ctx context.Context, resource T) error
+	// ResourceKind identifies the resource kind to a HeartbeatTokenAcquirer
+	// when rate-limiting fallback announces.
+	ResourceKind string
+	// TransportConfig caps the size of outgoing InventoryHeartbeat
+	// messages (optional, defaults to DefaultInventoryMaxMessageSize).
+	TransportConfig InventoryTransportConfig
+}
+
+func (c *genericHeartbeatV2Config[T]) checkAndSetDefaults() error {
+	if c.GetResource == nil {
+		return trace.BadParameter("missing required parameter GetResource for resource heartbeat")
+	}
+	if c.ToHeartbeat == nil {
+		return trace.BadParameter("missing required parameter ToHeartbeat for resource heartbeat")
+	}
+	if c.ResourceKind == "" {
+		return trace.BadParameter("missing required parameter ResourceKind for resource heartbeat")
+	}
+	if c.Compare == nil {
+		c.Compare = func(a, b T) bool { return reflect.DeepEqual(a, b) }
+	}
+	if err := c.TransportConfig.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// resourceHeartbeatV2 is a heartbeatV2Driver implementation generic over
+// resource type T.
+type resourceHeartbeatV2[T any] struct {
+	cfg         genericHeartbeatV2Config[T]
+	getMetadata metadataGetter
+	metadataPtr atomic.Pointer[metadata.Metadata]
+	prev        T
+	hasPrev     bool
+}
+
+func newResourceHeartbeatV2[T any](cfg genericHeartbeatV2Config[T]) *resourceHeartbeatV2[T] {
+	return &resourceHeartbeatV2[T]{
+		cfg:         cfg,
+		getMetadata: metadata.Get,
+	}
+}
+
+// getResource fetches the current resource value, injecting cloud
+// metadata the same way sshServerHeartbeatV2 does: the first call kicks
+// off a background metadata lookup, and every call after metadata
+// becomes available re-attaches it (since the resource isn't cached
+// between heartbeats).
+func (h *resourceHeartbeatV2[T]) getResource(ctx context.Context) T {
+	resource := h.cfg.GetResource()
+	if h.cfg.SetCloudMetadata == nil {
+		return resource
+	}
+
+	if meta := h.metadataPtr.Load(); meta == nil {
+		go func() {
+			meta, err := h.getMetadata(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warnf("Failed to get metadata: %v", err)
+				}
+				return
+			}
+			if meta != nil && meta.CloudMetadata != nil {
+				h.cfg.SetCloudMetadata(resource, meta.CloudMetadata)
+				h.metadataPtr.CompareAndSwap(nil, meta)
+			}
+		}()
+	} else if meta.CloudMetadata != nil {
+		h.cfg.SetCloudMetadata(resource, meta.CloudMetadata)
+	}
+
+	return resource
+}
+
+func (h *resourceHeartbeatV2[T]) Poll(ctx context.Context) (changed bool) {
+	if !h.hasPrev {
+		return true
+	}
+	return !h.cfg.Compare(h.getResource(ctx), h.prev)
+}
+
+func (h *resourceHeartbeatV2[T]) SupportsFallback() bool {
+	return h.cfg.FallbackUpsert != nil
+}
+
+func (h *resourceHeartbeatV2[T]) ResourceKind() string {
+	return h.cfg.ResourceKind
+}
+
+func (h *resourceHeartbeatV2[T]) FallbackAnnounce(ctx context.Context) (ok bool) {
+	if h.cfg.FallbackUpsert == nil {
+		return false
+	}
+	resource := h.getResource(ctx)
+	if err := h.cfg.FallbackUpsert(ctx, resource); err != nil {
+		log.Warnf("Failed to perform fallback heartbeat: %v", err)
+		return false
+	}
+	h.prev, h.hasPrev = resource, true
+	return true
+}
+
+func (h *resourceHeartbeatV2[T]) Announce(ctx context.Context, sender inventory.DownstreamSender) (ok bool) {
+	resource := h.getResource(ctx)
+	hb := h.cfg.ToHeartbeat(resource)
+	if !announceSizeOk(hb, h.cfg.TransportConfig.MaxMessageSize) {
+		log.Warnf("Dropping %s heartbeat: encoded size exceeds configured max message size %d", h.cfg.ResourceKind, h.cfg.TransportConfig.MaxMessageSize)
+		return false
+	}
+	if err := sender.Send(ctx, hb); err != nil {
+		log.Warnf("Failed to perform inventory heartbeat: %v", err)
+		return false
+	}
+	h.prev, h.hasPrev = resource, true
+	return true
+}
+
+// newResourceHeartbeat builds the HeartbeatV2 shared by every generic
+// resource kind's New*Heartbeat constructor.
+func newResourceHeartbeat[T any](handle inventory.DownstreamHandle, cfg genericHeartbeatV2Config[T], hbCfg heartbeatV2Config) (*HeartbeatV2, error) {
+	if err := cfg.checkAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return newHeartbeatV2(handle, newResourceHeartbeatV2(cfg), hbCfg), nil
+}
+
+// AppServerHeartbeatConfig configures the HeartbeatV2 for an app server.
+type AppServerHeartbeatConfig struct {
+	InventoryHandle  inventory.DownstreamHandle
+	GetServer        func() *types.AppServerV3
+	Announcer        AppServerAnnouncer
+	OnHeartbeat      func(error)
+	AnnounceInterval time.Duration
+	PollInterval     time.Duration
+	BackoffStrategy  BackoffStrategy
+	// TokenAcquirer gates fallback announces behind a fleet-wide rate
+	// limit (optional, defaults to unlimited).
+	TokenAcquirer HeartbeatTokenAcquirer
+	// HealthReporter receives structured health state transitions
+	// (optional).
+	HealthReporter HealthReporter
+	// TransportConfig caps the size of outgoing InventoryHeartbeat
+	// messages (optional, defaults to DefaultInventoryMaxMessageSize).
+	TransportConfig InventoryTransportConfig
+}
+
+// AppServerAnnouncer is the subset of auth.Announcer used to fallback
+// announce an app server.
+type AppServerAnnouncer interface {
+	UpsertApplicationServer(ctx context.Context, server *types.AppServerV3) error
+}
+
+// NewAppServerHeartbeat returns a HeartbeatV2 driving an app server's
+// inventory heartbeats, sharing resourceHeartbeatV2's cloud metadata
+// injection, degraded-state and fallback announce logic with every
+// other generic resource kind.
+func NewAppServerHeartbeat(cfg AppServerHeartbeatConfig) (*HeartbeatV2, error) {
+	if cfg.InventoryHandle == nil {
+		return nil, trace.BadParameter("missing required parameter InventoryHandle for app server heartbeat")
+	}
+	genericCfg := genericHeartbeatV2Config[*types.AppServerV3]{
+		GetResource:     cfg.GetServer,
+		ResourceKind:    "app_server",
+		TransportConfig: cfg.TransportConfig,
+		ToHeartbeat: func(server *types.AppServerV3) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{AppServer: server}
+		},
+	}
+	if cfg.Announcer != nil {
+		genericCfg.FallbackUpsert = func(ctx context.Context, server *types.AppServerV3) error {
+			return cfg.Announcer.UpsertApplicationServer(ctx, server)
+		}
+	}
+	return newResourceHeartbeat(cfg.InventoryHandle, genericCfg, heartbeatV2Config{
+		onHeartbeatInner: cfg.OnHeartbeat,
+		announceInterval: cfg.AnnounceInterval,
+		pollInterval:     cfg.PollInterval,
+		backoffStrategy:  cfg.BackoffStrategy,
+		tokenAcquirer:    cfg.TokenAcquirer,
+		healthReporter:   cfg.HealthReporter,
+	})
+}
+
+// DatabaseServerHeartbeatConfig configures the HeartbeatV2 for a database server.
+type DatabaseServerHeartbeatConfig struct {
+	InventoryHandle  inventory.DownstreamHandle
+	GetServer        func() *types.DatabaseServerV3
+	Announcer        DatabaseServerAnnouncer
+	OnHeartbeat      func(error)
+	AnnounceInterval time.Duration
+	PollInterval     time.Duration
+	BackoffStrategy  BackoffStrategy
+	// TokenAcquirer gates fallback announces behind a fleet-wide rate
+	// limit (optional, defaults to unlimited).
+	TokenAcquirer HeartbeatTokenAcquirer
+	// HealthReporter receives structured health state transitions
+	// (optional).
+	HealthReporter HealthReporter
+	// TransportConfig caps the size of outgoing InventoryHeartbeat
+	// messages (optional, defaults to DefaultInventoryMaxMessageSize).
+	TransportConfig InventoryTransportConfig
+}
+
+// DatabaseServerAnnouncer is the subset of auth.Announcer used to
+// fallback announce a database server.
+type DatabaseServerAnnouncer interface {
+	UpsertDatabaseServer(ctx context.Context, server *types.DatabaseServerV3) error
+}
+
+// NewDatabaseServerHeartbeat returns a HeartbeatV2 driving a database
+// server's inventory heartbeats.
+func NewDatabaseServerHeartbeat(cfg DatabaseServerHeartbeatConfig) (*HeartbeatV2, error) {
+	if cfg.InventoryHandle == nil {
+		return nil, trace.BadParameter("missing required parameter InventoryHandle for database server heartbeat")
+	}
+	genericCfg := genericHeartbeatV2Config[*types.DatabaseServerV3]{
+		GetResource:     cfg.GetServer,
+		ResourceKind:    "db_server",
+		TransportConfig: cfg.TransportConfig,
+		ToHeartbeat: func(server *types.DatabaseServerV3) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{DatabaseServer: server}
+		},
+	}
+	if cfg.Announcer != nil {
+		genericCfg.FallbackUpsert = func(ctx context.Context, server *types.DatabaseServerV3) error {
+			return cfg.Announcer.UpsertDatabaseServer(ctx, server)
+		}
+	}
+	return newResourceHeartbeat(cfg.InventoryHandle, genericCfg, heartbeatV2Config{
+		onHeartbeatInner: cfg.OnHeartbeat,
+		announceInterval: cfg.AnnounceInterval,
+		pollInterval:     cfg.PollInterval,
+		backoffStrategy:  cfg.BackoffStrategy,
+		tokenAcquirer:    cfg.TokenAcquirer,
+		healthReporter:   cfg.HealthReporter,
+	})
+}
+
+// KubernetesServerHeartbeatConfig configures the HeartbeatV2 for a
+// Kubernetes server.
+type KubernetesServerHeartbeatConfig struct {
+	InventoryHandle  inventory.DownstreamHandle
+	GetServer        func() *types.KubernetesServerV3
+	Announcer        KubernetesServerAnnouncer
+	OnHeartbeat      func(error)
+	AnnounceInterval time.Duration
+	PollInterval     time.Duration
+	BackoffStrategy  BackoffStrategy
+	// TokenAcquirer gates fallback announces behind a fleet-wide rate
+	// limit (optional, defaults to unlimited).
+	TokenAcquirer HeartbeatTokenAcquirer
+	// HealthReporter receives structured health state transitions
+	// (optional).
+	HealthReporter HealthReporter
+	// TransportConfig caps the size of outgoing InventoryHeartbeat
+	// messages (optional, defaults to DefaultInventoryMaxMessageSize).
+	TransportConfig InventoryTransportConfig
+}
+
+// KubernetesServerAnnouncer is the subset of auth.Announcer used to
+// fallback announce a Kubernetes server.
+type KubernetesServerAnnouncer interface {
+	UpsertKubernetesServer(ctx context.Context, server *types.KubernetesServerV3) error
+}
+
+// NewKubernetesServerHeartbeat returns a HeartbeatV2 driving a
+// Kubernetes server's inventory heartbeats.
+func NewKubernetesServerHeartbeat(cfg KubernetesServerHeartbeatConfig) (*HeartbeatV2, error) {
+	if cfg.InventoryHandle == nil {
+		return nil, trace.BadParameter("missing required parameter InventoryHandle for kubernetes server heartbeat")
+	}
+	genericCfg := genericHeartbeatV2Config[*types.KubernetesServerV3]{
+		GetResource:     cfg.GetServer,
+		ResourceKind:    "kube_server",
+		TransportConfig: cfg.TransportConfig,
+		ToHeartbeat: func(server *types.KubernetesServerV3) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{KubernetesServer: server}
+		},
+	}
+	if cfg.Announcer != nil {
+		genericCfg.FallbackUpsert = func(ctx context.Context, server *types.KubernetesServerV3) error {
+			return cfg.Announcer.UpsertKubernetesServer(ctx, server)
+		}
+	}
+	return newResourceHeartbeat(cfg.InventoryHandle, genericCfg, heartbeatV2Config{
+		onHeartbeatInner: cfg.OnHeartbeat,
+		announceInterval: cfg.AnnounceInterval,
+		pollInterval:     cfg.PollInterval,
+		backoffStrategy:  cfg.BackoffStrategy,
+		tokenAcquirer:    cfg.TokenAcquirer,
+		healthReporter:   cfg.HealthReporter,
+	})
+}
+
+// WindowsDesktopServiceHeartbeatConfig configures the HeartbeatV2 for a
+// Windows desktop service.
+type WindowsDesktopServiceHeartbeatConfig struct {
+	InventoryHandle  inventory.DownstreamHandle
+	GetService       func() *types.WindowsDesktopServiceV3
+	Announcer        WindowsDesktopServiceAnnouncer
+	OnHeartbeat      func(error)
+	AnnounceInterval time.Duration
+	PollInterval     time.Duration
+	BackoffStrategy  BackoffStrategy
+	// TokenAcquirer gates fallback announces behind a fleet-wide rate
+	// limit (optional, defaults to unlimited).
+	TokenAcquirer HeartbeatTokenAcquirer
+	// HealthReporter receives structured health state transitions
+	// (optional).
+	HealthReporter HealthReporter
+	// TransportConfig caps the size of outgoing InventoryHeartbeat
+	// messages (optional, defaults to DefaultInventoryMaxMessageSize).
+	TransportConfig InventoryTransportConfig
+}
+
+// WindowsDesktopServiceAnnouncer is the subset of auth.Announcer used to
+// fallback announce a Windows desktop service.
+type WindowsDesktopServiceAnnouncer interface {
+	UpsertWindowsDesktopService(ctx context.Context, service *types.WindowsDesktopServiceV3) error
+}
+
+// NewWindowsDesktopServiceHeartbeat returns a HeartbeatV2 driving a
+// Windows desktop service's inventory heartbeats.
+func NewWindowsDesktopServiceHeartbeat(cfg WindowsDesktopServiceHeartbeatConfig) (*HeartbeatV2, error) {
+	if cfg.InventoryHandle == nil {
+		return nil, trace.BadParameter("missing required parameter InventoryHandle for windows desktop service heartbeat")
+	}
+	genericCfg := genericHeartbeatV2Config[*types.WindowsDesktopServiceV3]{
+		GetResource:     cfg.GetService,
+		ResourceKind:    "windows_desktop_service",
+		TransportConfig: cfg.TransportConfig,
+		ToHeartbeat: func(service *types.WindowsDesktopServiceV3) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{WindowsDesktopService: service}
+		},
+	}
+	if cfg.Announcer != nil {
+		genericCfg.FallbackUpsert = func(ctx context.Context, service *types.WindowsDesktopServiceV3) error {
+			return cfg.Announcer.UpsertWindowsDesktopService(ctx, service)
+		}
+	}
+	return newResourceHeartbeat(cfg.InventoryHandle, genericCfg, heartbeatV2Config{
+		onHeartbeatInner: cfg.OnHeartbeat,
+		announceInterval: cfg.AnnounceInterval,
+		pollInterval:     cfg.PollInterval,
+		backoffStrategy:  cfg.BackoffStrategy,
+		tokenAcquirer:    cfg.TokenAcquirer,
+		healthReporter:   cfg.HealthReporter,
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/proto"
+)
+
+type fakeHeartbeatResource struct {
+	name string
+}
+
+func TestResourceHeartbeatV2PollDetectsChange(t *testing.T) {
+	current := &fakeHeartbeatResource{name: "a"}
+	driver := newResourceHeartbeatV2(genericHeartbeatV2Config[*fakeHeartbeatResource]{
+		GetResource:  func() *fakeHeartbeatResource { return current },
+		ResourceKind: "fake",
+		ToHeartbeat: func(r *fakeHeartbeatResource) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{}
+		},
+	})
+
+	require.NoError(t, driver.cfg.checkAndSetDefaults())
+	require.True(t, driver.Poll(context.Background()), "no previous resource, so Poll should report a change")
+
+	driver.prev, driver.hasPrev = current, true
+	require.False(t, driver.Poll(context.Background()), "identical resource shouldn't be reported as changed")
+
+	current = &fakeHeartbeatResource{name: "b"}
+	require.True(t, driver.Poll(context.Background()), "different resource should be reported as changed")
+}
+
+func TestResourceHeartbeatV2FallbackAnnounce(t *testing.T) {
+	var upserted *fakeHeartbeatResource
+	driver := newResourceHeartbeatV2(genericHeartbeatV2Config[*fakeHeartbeatResource]{
+		GetResource: func() *fakeHeartbeatResource { return &fakeHeartbeatResource{name: "a"} },
+		ToHeartbeat: func(r *fakeHeartbeatResource) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{}
+		},
+		FallbackUpsert: func(_ context.Context, r *fakeHeartbeatResource) error {
+			upserted = r
+			return nil
+		},
+	})
+
+	require.True(t, driver.SupportsFallback())
+	require.True(t, driver.FallbackAnnounce(context.Background()))
+	require.Equal(t, "a", upserted.name)
+	require.True(t, driver.hasPrev)
+}
+
+func TestResourceHeartbeatV2NoFallbackWithoutUpsert(t *testing.T) {
+	driver := newResourceHeartbeatV2(genericHeartbeatV2Config[*fakeHeartbeatResource]{
+		GetResource: func() *fakeHeartbeatResource { return &fakeHeartbeatResource{name: "a"} },
+		ToHeartbeat: func(r *fakeHeartbeatResource) proto.InventoryHeartbeat {
+			return proto.InventoryHeartbeat{}
+		},
+	})
+
+	require.False(t, driver.SupportsFallback())
+	require.False(t, driver.FallbackAnnounce(context.Background()))
+}
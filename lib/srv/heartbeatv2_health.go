@@ -0,0 +1,182 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthState is a coarse, structured summary of a HeartbeatV2's current
+// connectivity to auth, replacing the historical practice of inferring
+// degraded state by grepping logs for noSenderErr.
+type HealthState string
+
+const (
+	// HealthStateHealthy means the most recent announce attempt (via the
+	// control stream or the fallback path) succeeded.
+	HealthStateHealthy HealthState = "healthy"
+	// HealthStateAnnouncing means an announce attempt is currently in flight.
+	HealthStateAnnouncing HealthState = "announcing"
+	// HealthStateFallbackBackoff means the control stream is unavailable
+	// and the heartbeat is waiting out a fallback backoff (or fallback
+	// rate limit) window before retrying.
+	HealthStateFallbackBackoff HealthState = "fallback_backoff"
+	// HealthStateDegraded means announce attempts have been failing, or
+	// no control stream sender has been available for long enough that
+	// HeartbeatV2 considers itself degraded.
+	HealthStateDegraded HealthState = "degraded"
+	// HealthStateClosed means the heartbeat's run loop has exited.
+	HealthStateClosed HealthState = "closed"
+)
+
+// HealthStatus is a point-in-time snapshot published to a HealthReporter.
+type HealthStatus struct {
+	// State is the current coarse health state.
+	State HealthState
+	// LastAnnounceSuccess is the time of the most recent Healthy report:
+	// a successful announce, or a periodic degraded-state check on a live
+	// control stream that found nothing left to announce. It is the zero
+	// time if there hasn't been one yet.
+	LastAnnounceSuccess time.Time
+	// LastFailure is the time of the most recent failure, the zero time
+	// if there hasn't been one yet.
+	LastFailure time.Time
+	// LastError is the error from the most recent failure, nil if there
+	// hasn't been one yet.
+	LastError error
+}
+
+// HealthReporter receives HealthStatus updates from a HeartbeatV2 as its
+// connectivity to auth changes.
+type HealthReporter interface {
+	ReportHealth(status HealthStatus)
+}
+
+// HealthMonitor is a HealthReporter that keeps the latest HealthStatus
+// in memory, serves it over HTTP for use as an agent's /healthz and
+// /readyz endpoints, and fans it out to in-process subscribers (e.g. a
+// session recorder that wants to pause non-essential work while
+// degraded).
+//
+// The diagnostic HTTP listener that would mount HealthzHandler and
+// ReadyzHandler isn't part of this checkout (it lives alongside the
+// rest of the agent's process/service wiring); HealthMonitor is the
+// self-contained piece that listener would delegate to.
+type HealthMonitor struct {
+	mu     sync.Mutex
+	status HealthStatus
+	subs   map[chan HealthStatus]struct{}
+}
+
+// NewHealthMonitor returns a HealthMonitor with an initial HealthStateClosed
+// status, matching a HeartbeatV2 that hasn't started running yet.
+func NewHealthMonitor() *HealthMonitor {
+	return &HealthMonitor{
+		status: HealthStatus{State: HealthStateClosed},
+		subs:   make(map[chan HealthStatus]struct{}),
+	}
+}
+
+// ReportHealth implements HealthReporter.
+func (m *HealthMonitor) ReportHealth(status HealthStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status = status
+	for sub := range m.subs {
+		select {
+		case sub <- status:
+		default:
+			// drop the update rather than block a slow subscriber; Subscribe
+			// callers should always prefer Status() for point-in-time reads.
+		}
+	}
+}
+
+// Status returns the most recently reported HealthStatus.
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Subscribe returns a channel that receives every subsequent HealthStatus
+// update, and an unsubscribe function the caller must call when done to
+// release the channel.
+func (m *HealthMonitor) Subscribe() (<-chan HealthStatus, func()) {
+	ch := make(chan HealthStatus, 1)
+
+	m.mu.Lock()
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.subs[ch]; ok {
+			delete(m.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// isLive reports whether state represents a process that's still making
+// progress, even if not currently healthy (used for /healthz, which
+// orchestrators use to decide whether to restart a container/unit).
+func (s HealthState) isLive() bool {
+	return s != HealthStateClosed
+}
+
+// isReady reports whether state represents a heartbeat currently able to
+// serve traffic (used for /readyz, which orchestrators use to decide
+// whether to route traffic to this instance).
+func (s HealthState) isReady() bool {
+	return s == HealthStateHealthy || s == HealthStateAnnouncing
+}
+
+// HealthzHandler returns an http.HandlerFunc suitable for mounting at
+// /healthz: it reports 200 as long as the heartbeat's run loop is still
+// alive, and 503 once it has closed.
+func (m *HealthMonitor) HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := m.Status()
+		if !status.State.isLive() {
+			http.Error(w, string(status.State), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(status.State))
+	}
+}
+
+// ReadyzHandler returns an http.HandlerFunc suitable for mounting at
+// /readyz: it reports 200 only while the heartbeat is healthy or actively
+// announcing, and 503 while backing off, degraded, or closed.
+func (m *HealthMonitor) ReadyzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := m.Status()
+		if !status.State.isReady() {
+			http.Error(w, string(status.State), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(status.State))
+	}
+}
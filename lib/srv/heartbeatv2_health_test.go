@@ -0,0 +1,82 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthMonitorStatus(t *testing.T) {
+	m := NewHealthMonitor()
+	require.Equal(t, HealthStateClosed, m.Status().State)
+
+	now := time.Now()
+	m.ReportHealth(HealthStatus{State: HealthStateHealthy, LastAnnounceSuccess: now})
+	require.Equal(t, HealthStateHealthy, m.Status().State)
+	require.Equal(t, now, m.Status().LastAnnounceSuccess)
+}
+
+func TestHealthMonitorSubscribe(t *testing.T) {
+	m := NewHealthMonitor()
+	ch, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	m.ReportHealth(HealthStatus{State: HealthStateDegraded})
+	select {
+	case status := <-ch:
+		require.Equal(t, HealthStateDegraded, status.State)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for subscriber update")
+	}
+
+	unsubscribe()
+	_, ok := <-ch
+	require.False(t, ok, "channel should be closed after unsubscribe")
+}
+
+func TestHealthMonitorHealthzHandler(t *testing.T) {
+	m := NewHealthMonitor()
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	rec := httptest.NewRecorder()
+	m.HealthzHandler()(rec, req)
+	require.Equal(t, 503, rec.Code, "a fresh monitor starts in the closed state")
+
+	m.ReportHealth(HealthStatus{State: HealthStateDegraded})
+	rec = httptest.NewRecorder()
+	m.HealthzHandler()(rec, req)
+	require.Equal(t, 200, rec.Code, "degraded is still live")
+}
+
+func TestHealthMonitorReadyzHandler(t *testing.T) {
+	m := NewHealthMonitor()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	m.ReportHealth(HealthStatus{State: HealthStateFallbackBackoff})
+	rec := httptest.NewRecorder()
+	m.ReadyzHandler()(rec, req)
+	require.Equal(t, 503, rec.Code, "not ready while backing off")
+
+	m.ReportHealth(HealthStatus{State: HealthStateHealthy})
+	rec = httptest.NewRecorder()
+	m.ReadyzHandler()(rec, req)
+	require.Equal(t, 200, rec.Code, "ready once healthy")
+}
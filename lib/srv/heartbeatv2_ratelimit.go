@@ -0,0 +1,101 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HeartbeatTokenAcquirer gates HeartbeatV2's fallback announce path
+// behind a fleet-wide rate limit, so that many agents losing their
+// control stream at the same time (e.g. right after an auth restart)
+// don't all hammer auth with UpsertNode-style fallback calls at once.
+//
+// The real implementation is an RPC client calling the GetHeartbeatToken
+// method on auth (lib/auth.Server.AcquireHeartbeatFallbackToken, backed by
+// a server-side token bucket keyed by resource kind); that RPC's service
+// definition, registration, and client live outside this checkout, so
+// fleet-wide limiting isn't reachable from here. inMemoryTokenBucket
+// below applies the same token-bucket rule as auth's own
+// heartbeatFallbackLimiter, as an unexported, single-process
+// HeartbeatTokenAcquirer for use within this package (e.g. in tests) --
+// it only ever sees the heartbeats of the process that constructed it,
+// so it cannot by itself protect a multi-agent fleet from a reconnect
+// stampede.
+type HeartbeatTokenAcquirer interface {
+	// AcquireFallbackToken attempts to lease a single fallback-announce
+	// token for the given resource kind. granted is false (with a nil
+	// error) if the budget for kind is currently exhausted; the caller
+	// should back off and retry later rather than treat this as an
+	// announce failure.
+	AcquireFallbackToken(ctx context.Context, kind string) (granted bool, err error)
+}
+
+// inMemoryTokenBucket is a HeartbeatTokenAcquirer backed by one
+// token bucket per resource kind, refilled at a fixed rate.
+type inMemoryTokenBucket struct {
+	refillPerSecond float64
+	burst           float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucketState
+}
+
+type tokenBucketState struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// newInMemoryTokenBucket returns a HeartbeatTokenAcquirer that grants,
+// for each resource kind independently, up to burst tokens immediately
+// and refillPerSecond tokens every second thereafter.
+func newInMemoryTokenBucket(refillPerSecond, burst float64) *inMemoryTokenBucket {
+	return &inMemoryTokenBucket{
+		refillPerSecond: refillPerSecond,
+		burst:           burst,
+		buckets:         make(map[string]*tokenBucketState),
+	}
+}
+
+func (b *inMemoryTokenBucket) AcquireFallbackToken(_ context.Context, kind string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	state, ok := b.buckets[kind]
+	if !ok {
+		// start each newly-seen kind with a full bucket so the first
+		// wave of agents for that kind isn't needlessly throttled.
+		state = &tokenBucketState{tokens: b.burst, lastRefill: now}
+		b.buckets[kind] = state
+	}
+
+	elapsed := now.Sub(state.lastRefill).Seconds()
+	state.tokens += elapsed * b.refillPerSecond
+	if state.tokens > b.burst {
+		state.tokens = b.burst
+	}
+	state.lastRefill = now
+
+	if state.tokens < 1 {
+		return false, nil
+	}
+	state.tokens--
+	return true, nil
+}
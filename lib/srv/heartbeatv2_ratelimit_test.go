@@ -0,0 +1,68 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryTokenBucketRespectsBurst(t *testing.T) {
+	b := newInMemoryTokenBucket(0 /* no refill */, 3)
+
+	for i := 0; i < 3; i++ {
+		granted, err := b.AcquireFallbackToken(context.Background(), "node")
+		require.NoError(t, err)
+		require.True(t, granted, "burst token %d should be granted", i)
+	}
+
+	granted, err := b.AcquireFallbackToken(context.Background(), "node")
+	require.NoError(t, err)
+	require.False(t, granted, "bucket should be exhausted after burst tokens are spent")
+}
+
+func TestInMemoryTokenBucketRefills(t *testing.T) {
+	b := newInMemoryTokenBucket(1000 /* tokens/sec */, 1)
+
+	granted, err := b.AcquireFallbackToken(context.Background(), "node")
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	granted, err = b.AcquireFallbackToken(context.Background(), "node")
+	require.NoError(t, err)
+	require.False(t, granted, "bucket should be empty immediately after spending its only token")
+
+	require.Eventually(t, func() bool {
+		granted, err := b.AcquireFallbackToken(context.Background(), "node")
+		return err == nil && granted
+	}, time.Second, time.Millisecond, "bucket should refill given its high refill rate")
+}
+
+func TestInMemoryTokenBucketTracksKindsIndependently(t *testing.T) {
+	b := newInMemoryTokenBucket(0, 1)
+
+	granted, err := b.AcquireFallbackToken(context.Background(), "node")
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	granted, err = b.AcquireFallbackToken(context.Background(), "app_server")
+	require.NoError(t, err)
+	require.True(t, granted, "a different resource kind should have its own budget")
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/client/proto"
+)
+
+// DefaultInventoryMaxMessageSize is the default maximum size, in bytes,
+// of a single InventoryHeartbeat an agent sends over the inventory
+// control stream. It intentionally exceeds the
+// 64 KiB default grpc-websocket-proxy ships with: a server heartbeating
+// many labels or a large cloud metadata blob can easily produce an
+// InventoryHeartbeat payload north of that, and silently dropping the
+// message is worse than the extra buffer cost.
+const DefaultInventoryMaxMessageSize = 1024 * 1024 // 1 MiB
+
+// InventoryTransportConfig configures how an agent carries its
+// inventory control stream to auth.
+//
+// The control stream's gRPC and websocket dialing live in lib/inventory,
+// which isn't part of this checkout, so UseWebSocket itself isn't
+// consumed here; it's the agent-config-facing knob that the agent's
+// top-level config threads through to inventory.NewDownstreamHandle,
+// which is responsible for actually constructing the websocket
+// transport. MaxMessageSize, however, is enforced directly by
+// HeartbeatV2's drivers (see sshServerHeartbeatV2.Announce and
+// resourceHeartbeatV2.Announce): an outgoing InventoryHeartbeat that
+// would exceed it is dropped before being handed to the control stream,
+// as defense in depth regardless of whichever transport carries it.
+type InventoryTransportConfig struct {
+	// UseWebSocket carries the inventory control stream over a
+	// websocket (HTTP/1.1) connection instead of a raw gRPC stream, for
+	// agents sitting behind egress proxies that only permit HTTP
+	// traffic.
+	UseWebSocket bool
+	// MaxMessageSize caps the encoded size, in bytes, of a single
+	// InventoryHeartbeat the agent sends over the control stream;
+	// messages received from auth are not checked here. Zero means
+	// DefaultInventoryMaxMessageSize.
+	MaxMessageSize int
+}
+
+// CheckAndSetDefaults validates c and fills in defaults for unset fields.
+func (c *InventoryTransportConfig) CheckAndSetDefaults() error {
+	if c.MaxMessageSize < 0 {
+		return trace.BadParameter("MaxMessageSize must not be negative")
+	}
+	if c.MaxMessageSize == 0 {
+		c.MaxMessageSize = DefaultInventoryMaxMessageSize
+	}
+	return nil
+}
+
+// announceSizeOk reports whether hb's encoded size is within max. max <= 0
+// is treated as unlimited, which only happens if a driver is constructed
+// without going through InventoryTransportConfig.CheckAndSetDefaults.
+func announceSizeOk(hb proto.InventoryHeartbeat, max int) bool {
+	if max <= 0 {
+		return true
+	}
+	return gogoproto.Size(&hb) <= max
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/types"
+)
+
+func TestInventoryTransportConfigDefaults(t *testing.T) {
+	var cfg InventoryTransportConfig
+	require.NoError(t, cfg.CheckAndSetDefaults())
+	require.Equal(t, DefaultInventoryMaxMessageSize, cfg.MaxMessageSize)
+}
+
+func TestInventoryTransportConfigRejectsNegativeMaxMessageSize(t *testing.T) {
+	cfg := InventoryTransportConfig{MaxMessageSize: -1}
+	require.Error(t, cfg.CheckAndSetDefaults())
+}
+
+func TestInventoryTransportConfigPreservesExplicitMaxMessageSize(t *testing.T) {
+	cfg := InventoryTransportConfig{MaxMessageSize: 4096}
+	require.NoError(t, cfg.CheckAndSetDefaults())
+	require.Equal(t, 4096, cfg.MaxMessageSize)
+}
+
+func TestAnnounceSizeOkUnlimitedWhenMaxNotPositive(t *testing.T) {
+	require.True(t, announceSizeOk(proto.InventoryHeartbeat{}, 0))
+	require.True(t, announceSizeOk(proto.InventoryHeartbeat{}, -1))
+}
+
+func TestAnnounceSizeOkRejectsOversizedMessage(t *testing.T) {
+	require.True(t, announceSizeOk(proto.InventoryHeartbeat{}, 1), "empty heartbeat should fit in any positive budget")
+	require.False(t, announceSizeOk(proto.InventoryHeartbeat{SSHServer: &types.ServerV2{
+		Metadata: types.Metadata{Name: "a-very-long-server-name-used-only-to-push-the-encoded-size-over-the-configured-budget"},
+	}}, 1), "a non-trivial heartbeat shouldn't fit in a 1 byte budget")
+}
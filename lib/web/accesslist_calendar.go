@@ -0,0 +1,124 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/gravitational/teleport/api/types/accesslist"
+	"github.com/gravitational/teleport/lib/services/local/accesslist/ical"
+)
+
+// getAccessListsCalendar is a handler for GET
+// /webapi/accesslists/calendar.ics. It renders a VCALENDAR of the
+// caller's owned access lists' upcoming audits as an iCalendar feed
+// suitable for subscribing to from a calendar client.
+//
+// NOTE: the route itself is registered alongside the rest of
+// /webapi/accesslists/* in the main API router; this snapshot doesn't
+// carry that router file, so the registration can't be wired up here.
+func (h *Handler) getAccessListsCalendar(w http.ResponseWriter, r *http.Request, _ httprouter.Params, sctx *SessionContext) (any, error) {
+	authClient, err := sctx.GetClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	lists, err := ownedAccessLists(r.Context(), authClient, sctx.GetUser())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	cal, err := ical.RenderCalendar(lists, nil, time.Now())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="access-lists.ics"`)
+	_, err = w.Write([]byte(cal))
+	return nil, trace.Wrap(err)
+}
+
+// handleAccessListsCalDAV is a handler for PROPFIND/REPORT requests
+// against /webapi/accesslists/calendar.ics, allowing CalDAV clients to
+// discover and subscribe to the feed directly rather than treating it as
+// a one-shot download.
+//
+// NOTE: same caveat as getAccessListsCalendar above — route registration
+// lives in the main API router, which this snapshot doesn't carry.
+func (h *Handler) handleAccessListsCalDAV(w http.ResponseWriter, r *http.Request, p httprouter.Params, sctx *SessionContext) (any, error) {
+	switch r.Method {
+	case "PROPFIND":
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("DAV", "1, calendar-access")
+		w.WriteHeader(http.StatusMultiStatus)
+		_, err := w.Write([]byte(calDAVPropfindResponse(r.URL.Path)))
+		return nil, trace.Wrap(err)
+	case "REPORT":
+		return h.getAccessListsCalendar(w, r, p, sctx)
+	default:
+		return nil, trace.BadParameter("unsupported CalDAV method %q", r.Method)
+	}
+}
+
+// ownedAccessLists collects every access list owned by owner, following
+// ListAccessListsByOwner's pagination to completion.
+func ownedAccessLists(ctx context.Context, authClient accessListsClient, owner string) ([]*accesslist.AccessList, error) {
+	var lists []*accesslist.AccessList
+	nextToken := ""
+	for {
+		page, token, err := authClient.ListAccessListsByOwner(ctx, owner, 0, nextToken)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		lists = append(lists, page...)
+		if token == "" {
+			break
+		}
+		nextToken = token
+	}
+	return lists, nil
+}
+
+// accessListsClient is the subset of the auth client used to render the
+// calendar feed.
+type accessListsClient interface {
+	ListAccessListsByOwner(ctx context.Context, owner string, pageSize int, nextToken string) ([]*accesslist.AccessList, string, error)
+}
+
+// calDAVPropfindResponse renders a minimal multistatus response
+// describing the calendar resource at path, enough for clients to
+// discover that it supports the calendar-access privilege set.
+func calDAVPropfindResponse(path string) string {
+	var escapedPath bytes.Buffer
+	_ = xml.EscapeText(&escapedPath, []byte(path))
+
+	return `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+		`<D:response><D:href>` + escapedPath.String() + `</D:href>` +
+		`<D:propstat><D:prop><D:resourcetype><D:collection/><C:calendar/></D:resourcetype>` +
+		`<C:supported-calendar-component-set><C:comp name="VTODO"/><C:comp name="VEVENT"/></C:supported-calendar-component-set>` +
+		`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>` +
+		`</D:multistatus>`
+}
@@ -0,0 +1,44 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCalDAVPropfindResponseEscapesPath guards against reflected XML
+// injection through the request path: an attacker-controlled path
+// containing markup must come back as inert text, not additional XML
+// elements, and the document as a whole must still be well-formed.
+func TestCalDAVPropfindResponseEscapesPath(t *testing.T) {
+	const maliciousPath = `/webapi/accesslists/calendar.ics"><D:response><D:href>injected`
+
+	body := calDAVPropfindResponse(maliciousPath)
+
+	require.NotContains(t, body, "<D:response><D:href>injected")
+	require.True(t, strings.Contains(body, "&gt;") || strings.Contains(body, "&lt;"),
+		"expected markup characters in the path to be escaped")
+
+	var out struct {
+		XMLName xml.Name `xml:"multistatus"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(body), &out), "response must remain well-formed XML")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package preferencesbackend lets a cluster mirror user preferences to an
+// external store in addition to the auth server, so preferences survive
+// a disaster-recovery failover to a cluster that doesn't share the
+// original's backend. Handler.getUserPreferences/updateUserPreferences
+// in lib/web treat a configured Backend as a best-effort mirror: the
+// auth server remains the source of truth for reads and the system of
+// record for writes.
+package preferencesbackend
+
+import (
+	"context"
+
+	userpreferencesv1 "github.com/gravitational/teleport/api/gen/proto/go/userpreferences/v1"
+)
+
+// Backend mirrors a single user's preferences to an external store,
+// using an ETag for optimistic concurrency analogous to backend.Item's
+// Revision.
+type Backend interface {
+	// Get fetches username's preferences and their current ETag. It
+	// returns trace.NotFound if the external store has never seen this
+	// user.
+	Get(ctx context.Context, username string) (prefs *userpreferencesv1.UserPreferences, etag string, err error)
+	// Put writes username's preferences, succeeding only if the stored
+	// ETag still matches ifMatchEtag (or ifMatchEtag is empty, meaning
+	// "create, don't overwrite"). It returns trace.CompareFailed on a
+	// mismatch and the new ETag on success.
+	Put(ctx context.Context, username string, prefs *userpreferencesv1.UserPreferences, ifMatchEtag string) (etag string, err error)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preferencesbackend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gravitational/trace"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	userpreferencesv1 "github.com/gravitational/teleport/api/gen/proto/go/userpreferences/v1"
+)
+
+// S3Client is the subset of the AWS SDK's S3 client used by S3Backend,
+// so tests can substitute a fake without standing up real S3.
+type S3Client interface {
+	GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Backend mirrors user preferences as one JSON object per user in an
+// S3 (or S3-compatible) bucket, keyed by a configurable prefix. It uses
+// S3's conditional-write support (If-Match/If-None-Match) for the same
+// optimistic concurrency backend.Backend gets from revisions.
+type S3Backend struct {
+	client S3Client
+	bucket string
+	prefix string
+}
+
+// NewS3Backend creates an S3Backend storing objects in bucket under
+// prefix (e.g. "user-preferences/").
+func NewS3Backend(client S3Client, bucket, prefix string) *S3Backend {
+	return &S3Backend{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+func (s *S3Backend) key(username string) string {
+	return strings.TrimSuffix(s.prefix, "/") + "/" + username + ".json"
+}
+
+// Get implements Backend.
+func (s *S3Backend) Get(ctx context.Context, username string) (*userpreferencesv1.UserPreferences, string, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(username)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", trace.NotFound("no preferences stored in S3 for user %q", username)
+		}
+		return nil, "", trace.Wrap(err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+
+	var prefs userpreferencesv1.UserPreferences
+	if err := protojson.Unmarshal(data, &prefs); err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+
+	return &prefs, aws.ToString(out.ETag), nil
+}
+
+// Put implements Backend.
+func (s *S3Backend) Put(ctx context.Context, username string, prefs *userpreferencesv1.UserPreferences, ifMatchEtag string) (string, error) {
+	data, err := protojson.Marshal(prefs)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(username)),
+		Body:   bytes.NewReader(data),
+	}
+	if ifMatchEtag == "" {
+		in.IfNoneMatch = aws.String("*")
+	} else {
+		in.IfMatch = aws.String(ifMatchEtag)
+	}
+
+	out, err := s.client.PutObject(ctx, in)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return "", trace.CompareFailed("preferences for user %q were modified concurrently", username)
+		}
+		return "", trace.Wrap(err)
+	}
+
+	return aws.ToString(out.ETag), nil
+}
+
+// isPreconditionFailed reports whether err is the 412 S3 returns when an
+// If-Match/If-None-Match condition isn't met.
+func isPreconditionFailed(err error) bool {
+	return strings.Contains(err.Error(), "PreconditionFailed") || strings.Contains(err.Error(), "412")
+}
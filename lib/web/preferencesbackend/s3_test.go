@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preferencesbackend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	userpreferencesv1 "github.com/gravitational/teleport/api/gen/proto/go/userpreferences/v1"
+)
+
+type fakeS3Client struct {
+	objects map[string][]byte
+	etags   map[string]string
+	nextTag int
+}
+
+func newFakeS3Client() *fakeS3Client {
+	return &fakeS3Client{
+		objects: make(map[string][]byte),
+		etags:   make(map[string]string),
+	}
+}
+
+func (f *fakeS3Client) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{
+		Body: io.NopCloser(bytes.NewReader(data)),
+		ETag: aws.String(f.etags[key]),
+	}, nil
+}
+
+func (f *fakeS3Client) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	current, exists := f.etags[key]
+
+	switch {
+	case in.IfNoneMatch != nil && exists:
+		return nil, trace412{}
+	case in.IfMatch != nil && aws.ToString(in.IfMatch) != current:
+		return nil, trace412{}
+	}
+
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.nextTag++
+	tag := string(rune('a' + f.nextTag))
+	f.objects[key] = data
+	f.etags[key] = tag
+
+	return &s3.PutObjectOutput{ETag: aws.String(tag)}, nil
+}
+
+type trace404 struct{}
+
+func (trace404) Error() string { return "NoSuchKey: not found" }
+
+type trace412 struct{}
+
+func (trace412) Error() string { return "PreconditionFailed: 412" }
+
+func TestS3BackendPutThenGet(t *testing.T) {
+	client := newFakeS3Client()
+	backend := NewS3Backend(client, "bucket", "prefs/")
+
+	prefs := &userpreferencesv1.UserPreferences{Theme: 1}
+
+	etag, err := backend.Put(context.Background(), "alice", prefs, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, etag)
+
+	got, gotEtag, err := backend.Get(context.Background(), "alice")
+	require.NoError(t, err)
+	require.Equal(t, etag, gotEtag)
+	require.Equal(t, prefs.Theme, got.Theme)
+}
+
+func TestS3BackendRejectsCreateOverExisting(t *testing.T) {
+	client := newFakeS3Client()
+	backend := NewS3Backend(client, "bucket", "prefs/")
+
+	prefs := &userpreferencesv1.UserPreferences{Theme: 1}
+	_, err := backend.Put(context.Background(), "alice", prefs, "")
+	require.NoError(t, err)
+
+	_, err = backend.Put(context.Background(), "alice", prefs, "")
+	require.Error(t, err)
+}
+
+func TestS3BackendRejectsStaleIfMatch(t *testing.T) {
+	client := newFakeS3Client()
+	backend := NewS3Backend(client, "bucket", "prefs/")
+
+	prefs := &userpreferencesv1.UserPreferences{Theme: 1}
+	_, err := backend.Put(context.Background(), "alice", prefs, "")
+	require.NoError(t, err)
+
+	_, err = backend.Put(context.Background(), "alice", prefs, "stale-etag")
+	require.Error(t, err)
+}
+
+func TestS3BackendGetMissingIsNotFound(t *testing.T) {
+	client := newFakeS3Client()
+	backend := NewS3Backend(client, "bucket", "prefs/")
+
+	_, _, err := backend.Get(context.Background(), "nobody")
+	require.Error(t, err)
+	require.True(t, trace.IsNotFound(err), "expected not found error, got %v", err)
+}
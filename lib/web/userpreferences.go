@@ -17,10 +17,15 @@
 package web
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/gravitational/trace"
 	"github.com/julienschmidt/httprouter"
+	"google.golang.org/protobuf/proto"
 
 	userpreferencesv1 "github.com/gravitational/teleport/api/gen/proto/go/userpreferences/v1"
 	"github.com/gravitational/teleport/lib/httplib"
@@ -61,9 +66,15 @@ type UserPreferencesResponse struct {
 	UnifiedResourcePreferences UnifiedResourcePreferencesResponse `json:"unifiedResourcePreferences"`
 	Onboard                    OnboardUserPreferencesResponse     `json:"onboard"`
 	ClusterPreferences         ClusterUserPreferencesResponse     `json:"clusterPreferences,omitempty"`
+	// SchemaVersion is the shape this payload was authored against. A
+	// client that predates schema versioning omits it, which is
+	// equivalent to sending 0. updateUserPreferences runs every payload
+	// through migrateUserPreferences before validating or storing it, so
+	// the rest of the handler can assume currentUserPreferencesSchemaVersion.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
 }
 
-func (h *Handler) getUserClusterPreferences(_ http.ResponseWriter, r *http.Request, p httprouter.Params, sctx *SessionContext, site reversetunnelclient.RemoteSite) (interface{}, error) {
+func (h *Handler) getUserClusterPreferences(w http.ResponseWriter, r *http.Request, p httprouter.Params, sctx *SessionContext, site reversetunnelclient.RemoteSite) (interface{}, error) {
 	authClient, err := sctx.GetUserClient(r.Context(), site)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -74,7 +85,14 @@ func (h *Handler) getUserClusterPreferences(_ http.ResponseWriter, r *http.Reque
 		return nil, trace.Wrap(err)
 	}
 
-	return clusterPreferencesResponse(resp.Preferences.ClusterPreferences), nil
+	clusterPreferences := clusterPreferencesResponse(resp.Preferences.ClusterPreferences)
+	clean, dropped := sanitizePinnedResources(clusterPreferences.PinnedResources)
+	clusterPreferences.PinnedResources = clean
+	if dropped > 0 {
+		w.Header().Set("Warning", pinnedResourcesWarningHeader(dropped))
+	}
+
+	return clusterPreferences, nil
 }
 
 // updateUserClusterPreferences is a handler for PUT /webapi/user/preferences.
@@ -85,6 +103,11 @@ func (h *Handler) updateUserClusterPreferences(_ http.ResponseWriter, r *http.Re
 		return nil, trace.Wrap(err)
 	}
 
+	req = migrateUserPreferences(req)
+	if err := validateUserPreferences(req); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	authClient, err := sctx.GetUserClient(r.Context(), site)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -100,7 +123,7 @@ func (h *Handler) updateUserClusterPreferences(_ http.ResponseWriter, r *http.Re
 }
 
 // getUserPreferences is a handler for GET /webapi/user/preferences.
-func (h *Handler) getUserPreferences(_ http.ResponseWriter, r *http.Request, _ httprouter.Params, sctx *SessionContext) (any, error) {
+func (h *Handler) getUserPreferences(w http.ResponseWriter, r *http.Request, _ httprouter.Params, sctx *SessionContext) (any, error) {
 	authClient, err := sctx.GetClient()
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -111,7 +134,56 @@ func (h *Handler) getUserPreferences(_ http.ResponseWriter, r *http.Request, _ h
 		return nil, trace.Wrap(err)
 	}
 
-	return userPreferencesResponse(resp.Preferences), nil
+	etag, err := preferencesETag(resp.Preferences)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	w.Header().Set("ETag", etag)
+
+	jsonResp := userPreferencesResponse(resp.Preferences)
+	clean, dropped := sanitizePinnedResources(jsonResp.ClusterPreferences.PinnedResources)
+	jsonResp.ClusterPreferences.PinnedResources = clean
+	if dropped > 0 {
+		w.Header().Set("Warning", pinnedResourcesWarningHeader(dropped))
+	}
+
+	return jsonResp, nil
+}
+
+// preferencesETag derives a stable ETag from prefs' canonical wire
+// representation, so callers can detect concurrent modification via
+// If-Match without the auth server needing to track a separate revision
+// for this one resource.
+func preferencesETag(prefs *userpreferencesv1.UserPreferences) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(prefs)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// mirrorUserPreferences best-effort mirrors prefs to the configured
+// PreferencesBackend (HandlerConfig.PreferencesBackend, wired up
+// alongside the rest of HandlerConfig in apiserver.go), so a
+// disaster-recovery cluster without access to this cluster's auth
+// server backend can still recover them. Mirroring failures are logged,
+// not surfaced to the caller: the auth server write already succeeded
+// and remains the source of truth.
+func (h *Handler) mirrorUserPreferences(r *http.Request, username string, prefs *userpreferencesv1.UserPreferences) {
+	if h.cfg.PreferencesBackend == nil {
+		return
+	}
+
+	_, existingEtag, err := h.cfg.PreferencesBackend.Get(r.Context(), username)
+	if err != nil && !trace.IsNotFound(err) {
+		log.WithError(err).Warn("Failed to read mirrored user preferences.")
+		return
+	}
+
+	if _, err := h.cfg.PreferencesBackend.Put(r.Context(), username, prefs, existingEtag); err != nil {
+		log.WithError(err).Warn("Failed to mirror user preferences.")
+	}
 }
 
 func makePreferenceRequest(req UserPreferencesResponse) *userpreferencesv1.UpsertUserPreferencesRequest {
@@ -144,23 +216,69 @@ func makePreferenceRequest(req UserPreferencesResponse) *userpreferencesv1.Upser
 }
 
 // updateUserPreferences is a handler for PUT /webapi/user/preferences.
-func (h *Handler) updateUserPreferences(_ http.ResponseWriter, r *http.Request, _ httprouter.Params, sctx *SessionContext) (any, error) {
+// It remains a single round-trip update of the whole document, but
+// internally it's a thin wrapper around the same per-section machinery
+// backing PUT /webapi/user/preferences/{section}: it diffs the request
+// against the caller's current preferences section by section, checks
+// access to every changed section before writing anything so a single
+// denied section fails the whole request atomically, and emits one
+// audit event per changed section. An If-Match header, if present, must
+// match the ETag of the preferences currently stored for the caller, or
+// the update is rejected with trace.CompareFailed so two tabs editing
+// preferences concurrently don't silently clobber one another.
+func (h *Handler) updateUserPreferences(w http.ResponseWriter, r *http.Request, _ httprouter.Params, sctx *SessionContext) (any, error) {
 	var req UserPreferencesResponse
 
 	if err := httplib.ReadJSON(r, &req); err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	req = migrateUserPreferences(req)
+	if err := validateUserPreferences(req); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	authClient, err := sctx.GetClient()
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	current, err := authClient.GetUserPreferences(r.Context(), &userpreferencesv1.GetUserPreferencesRequest{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	currentEtag, err := preferencesETag(current.Preferences)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != currentEtag {
+		return nil, trace.CompareFailed("user preferences were modified concurrently")
+	}
+
+	changes := diffPreferencesSections(sctx.GetUser(), userPreferencesResponse(current.Preferences), &req)
+	for _, change := range changes {
+		if err := h.checkPreferencesSectionAccess(r.Context(), sctx, change.Section); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+
 	preferences := makePreferenceRequest(req)
 	if err := authClient.UpsertUserPreferences(r.Context(), preferences); err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	for _, change := range changes {
+		h.emitPreferencesSectionAuditEvent(r.Context(), change)
+	}
+
+	etag, err := preferencesETag(preferences.Preferences)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	w.Header().Set("ETag", etag)
+
+	h.mirrorUserPreferences(r, sctx.GetUser(), preferences.Preferences)
+
 	return OK(), nil
 }
 
@@ -172,6 +290,7 @@ func userPreferencesResponse(resp *userpreferencesv1.UserPreferences) *UserPrefe
 		Onboard:                    onboardUserPreferencesResponse(resp.Onboard),
 		ClusterPreferences:         clusterPreferencesResponse(resp.ClusterPreferences),
 		UnifiedResourcePreferences: unifiedResourcePreferencesResponse(resp.UnifiedResourcePreferences),
+		SchemaVersion:              currentUserPreferencesSchemaVersion,
 	}
 
 	return jsonResp
@@ -0,0 +1,149 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/gravitational/trace"
+	"github.com/julienschmidt/httprouter"
+
+	userpreferencesv1 "github.com/gravitational/teleport/api/gen/proto/go/userpreferences/v1"
+)
+
+// mergePatchContentType and jsonPatchContentType are the two partial-update
+// formats patchUserPreferences accepts.
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchUserPreferences is a handler for PATCH /webapi/user/preferences
+// (route registration lives alongside the rest of the user preferences
+// routes in apiserver.go, absent from this snapshot). It applies either
+// an RFC 7396 JSON Merge Patch or an RFC 6902 JSON Patch against the
+// caller's currently stored preferences and writes back the merged
+// result in one round trip, so two tabs updating different sections
+// (e.g. Assist view mode and pinned resources) don't need to coordinate
+// a full read-modify-write of the whole document themselves. An
+// If-Match header, if present, must match the ETag of the preferences
+// this patch was computed against, or the request is rejected with
+// trace.CompareFailed.
+func (h *Handler) patchUserPreferences(w http.ResponseWriter, r *http.Request, _ httprouter.Params, sctx *SessionContext) (any, error) {
+	authClient, err := sctx.GetClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	current, err := authClient.GetUserPreferences(r.Context(), &userpreferencesv1.GetUserPreferencesRequest{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	currentEtag, err := preferencesETag(current.Preferences)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != currentEtag {
+		return nil, trace.CompareFailed("user preferences were modified concurrently")
+	}
+
+	currentJSON, err := json.Marshal(userPreferencesResponse(current.Preferences))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	patchBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	mergedJSON, err := applyUserPreferencesPatch(r.Header.Get("Content-Type"), currentJSON, patchBody)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var merged UserPreferencesResponse
+	if err := json.Unmarshal(mergedJSON, &merged); err != nil {
+		return nil, trace.BadParameter("patched preferences are not valid JSON: %v", err)
+	}
+
+	merged = migrateUserPreferences(merged)
+	if err := validateUserPreferences(merged); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	changes := diffPreferencesSections(sctx.GetUser(), userPreferencesResponse(current.Preferences), &merged)
+	for _, change := range changes {
+		if err := h.checkPreferencesSectionAccess(r.Context(), sctx, change.Section); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+
+	preferences := makePreferenceRequest(merged)
+	if err := authClient.UpsertUserPreferences(r.Context(), preferences); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	for _, change := range changes {
+		h.emitPreferencesSectionAuditEvent(r.Context(), change)
+	}
+
+	newEtag, err := preferencesETag(preferences.Preferences)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	w.Header().Set("ETag", newEtag)
+
+	h.mirrorUserPreferences(r, sctx.GetUser(), preferences.Preferences)
+
+	return userPreferencesResponse(preferences.Preferences), nil
+}
+
+// applyUserPreferencesPatch applies patch to original according to the
+// format named by contentType, returning the merged document.
+func applyUserPreferencesPatch(contentType string, original, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, trace.BadParameter("invalid Content-Type: %v", err)
+	}
+
+	switch mediaType {
+	case mergePatchContentType:
+		merged, err := jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return nil, trace.BadParameter("invalid merge patch: %v", err)
+		}
+		return merged, nil
+	case jsonPatchContentType:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, trace.BadParameter("invalid JSON patch: %v", err)
+		}
+		merged, err := ops.Apply(original)
+		if err != nil {
+			return nil, trace.BadParameter("failed to apply JSON patch: %v", err)
+		}
+		return merged, nil
+	default:
+		return nil, trace.BadParameter("unsupported Content-Type %q, expected %q or %q", contentType, mergePatchContentType, jsonPatchContentType)
+	}
+}
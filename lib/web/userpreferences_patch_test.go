@@ -0,0 +1,48 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyUserPreferencesPatchMergePatch(t *testing.T) {
+	original := []byte(`{"theme":1,"assist":{"viewMode":2}}`)
+	patch := []byte(`{"assist":{"viewMode":3}}`)
+
+	merged, err := applyUserPreferencesPatch(mergePatchContentType, original, patch)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"theme":1,"assist":{"viewMode":3}}`, string(merged))
+}
+
+func TestApplyUserPreferencesPatchJSONPatch(t *testing.T) {
+	original := []byte(`{"theme":1,"assist":{"viewMode":2}}`)
+	patch := []byte(`[{"op":"replace","path":"/theme","value":2}]`)
+
+	merged, err := applyUserPreferencesPatch(jsonPatchContentType, original, patch)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"theme":2,"assist":{"viewMode":2}}`, string(merged))
+}
+
+func TestApplyUserPreferencesPatchRejectsUnknownContentType(t *testing.T) {
+	_, err := applyUserPreferencesPatch("text/plain", []byte(`{}`), []byte(`{}`))
+	require.Error(t, err)
+	require.True(t, trace.IsBadParameter(err))
+}
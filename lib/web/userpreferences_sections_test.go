@@ -0,0 +1,53 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferencesSectionWriteVerb(t *testing.T) {
+	require.Equal(t, "user_preferences.assist:write", PreferencesSectionAssist.writeVerb())
+	require.Equal(t, "user_preferences.cluster_pinned:write", PreferencesSectionClusterPinned.writeVerb())
+}
+
+func TestDiffPreferencesSections(t *testing.T) {
+	before := &UserPreferencesResponse{
+		Theme:              1,
+		Assist:             AssistUserPreferencesResponse{PreferredLogins: []string{"root"}},
+		ClusterPreferences: ClusterUserPreferencesResponse{PinnedResources: []string{"node/a"}},
+	}
+	after := &UserPreferencesResponse{
+		Theme:              2,
+		Assist:             AssistUserPreferencesResponse{PreferredLogins: []string{"root"}},
+		ClusterPreferences: ClusterUserPreferencesResponse{PinnedResources: []string{"node/a", "node/b"}},
+	}
+
+	changes := diffPreferencesSections("alice", before, after)
+
+	require.Len(t, changes, 2)
+	require.Equal(t, PreferencesSectionTheme, changes[0].Section)
+	require.Equal(t, "alice", changes[0].User)
+	require.Equal(t, PreferencesSectionClusterPinned, changes[1].Section)
+}
+
+func TestDiffPreferencesSectionsNoChanges(t *testing.T) {
+	resp := &UserPreferencesResponse{Theme: 1}
+	require.Empty(t, diffPreferencesSections("alice", resp, resp))
+}
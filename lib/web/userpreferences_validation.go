@@ -0,0 +1,156 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gravitational/trace"
+
+	awsutils "github.com/gravitational/teleport/api/utils/aws"
+)
+
+// currentUserPreferencesSchemaVersion is the shape updateUserPreferences
+// validates against and migrateUserPreferences upgrades older payloads
+// to, via SchemaVersion on UserPreferencesResponse.
+const currentUserPreferencesSchemaVersion = 1
+
+// posixLoginPattern matches a POSIX-portable username: starts with a
+// lowercase letter or underscore, then lowercase letters, digits,
+// underscores or hyphens,
+// with an optional trailing '$' (the traditional marker for machine
+// accounts).
+var posixLoginPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+
+// resourceIDPattern matches the charset PinnedResources entries are
+// expected to use: resource UUIDs, AWS ARNs, and similar colon/slash
+// delimited identifiers.
+var resourceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_:/.@=,+-]+$`)
+
+// userPreferencesMigrations upgrades a UserPreferencesResponse from the
+// schema version given by its map key to the next one. migrateUserPreferences
+// applies them in order, so adding support for an old client shape is a
+// single new entry rather than a rewrite of the migration function.
+var userPreferencesMigrations = map[int]func(UserPreferencesResponse) UserPreferencesResponse{
+	0: migrateV0ToV1,
+}
+
+// migrateV0ToV1 upgrades a pre-schema-versioning (v0) payload. v0
+// clients never set PinnedResources explicitly, so nil and empty are
+// conflated; normalize to empty so later validation and comparisons
+// don't need to special-case nil.
+func migrateV0ToV1(req UserPreferencesResponse) UserPreferencesResponse {
+	if req.ClusterPreferences.PinnedResources == nil {
+		req.ClusterPreferences.PinnedResources = []string{}
+	}
+	return req
+}
+
+// migrateUserPreferences upgrades req from whatever SchemaVersion it
+// arrived with (0 if unset, i.e. a client predating schema versioning)
+// to currentUserPreferencesSchemaVersion.
+func migrateUserPreferences(req UserPreferencesResponse) UserPreferencesResponse {
+	for v := req.SchemaVersion; v < currentUserPreferencesSchemaVersion; v++ {
+		if migrate, ok := userPreferencesMigrations[v]; ok {
+			req = migrate(req)
+		}
+	}
+	req.SchemaVersion = currentUserPreferencesSchemaVersion
+	return req
+}
+
+// validateUserPreferences checks every field with a defined format,
+// collecting every violation (rather than failing on the first) so the
+// caller gets back the complete list of offending fields in one 400.
+func validateUserPreferences(req UserPreferencesResponse) error {
+	var errs []error
+
+	for _, login := range req.Assist.PreferredLogins {
+		if !posixLoginPattern.MatchString(login) {
+			errs = append(errs, trace.BadParameter("assist.preferredLogins: %q is not a valid POSIX login", login))
+		}
+	}
+
+	for _, id := range req.ClusterPreferences.PinnedResources {
+		if id == "" || !resourceIDPattern.MatchString(id) {
+			errs = append(errs, trace.BadParameter("clusterPreferences.pinnedResources: %q is not a valid resource id", id))
+			continue
+		}
+		if err := validateAWSPinnedResource(id); err != nil {
+			errs = append(errs, trace.BadParameter("clusterPreferences.pinnedResources: %v", err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return trace.NewAggregate(errs...)
+}
+
+// Pinned resource ids that encode an AWS identifier carry one of these
+// prefixes ahead of the AWS-specific value, e.g. "arn:aws:iam::123456789012:role/foo"
+// or "aws-region:us-east-1". Anything without one of these prefixes is
+// treated as a non-AWS resource id and skips AWS-specific validation.
+const (
+	pinnedResourceARNPrefix     = "arn:"
+	pinnedResourceAccountPrefix = "aws-account:"
+	pinnedResourceRegionPrefix  = "aws-region:"
+	pinnedResourceRolePrefix    = "aws-role:"
+)
+
+// validateAWSPinnedResource checks id against the matching AWS identifier
+// format if id carries a recognized AWS prefix. It reports nil for ids
+// that don't encode an AWS identifier at all.
+func validateAWSPinnedResource(id string) error {
+	switch {
+	case strings.HasPrefix(id, pinnedResourceARNPrefix):
+		return awsutils.CheckRoleARN(id)
+	case strings.HasPrefix(id, pinnedResourceAccountPrefix):
+		return awsutils.IsValidAccountID(strings.TrimPrefix(id, pinnedResourceAccountPrefix))
+	case strings.HasPrefix(id, pinnedResourceRegionPrefix):
+		return awsutils.IsValidRegion(strings.TrimPrefix(id, pinnedResourceRegionPrefix))
+	case strings.HasPrefix(id, pinnedResourceRolePrefix):
+		return awsutils.IsValidIAMRoleName(strings.TrimPrefix(id, pinnedResourceRolePrefix))
+	default:
+		return nil
+	}
+}
+
+// sanitizePinnedResources drops any entry that carries a recognized AWS
+// prefix but fails that identifier's validation, so a pin corrupted
+// after being written (e.g. by a future format change) doesn't poison
+// the UI. It reports how many entries were dropped.
+func sanitizePinnedResources(ids []string) (clean []string, dropped int) {
+	clean = make([]string, 0, len(ids))
+	for _, id := range ids {
+		if err := validateAWSPinnedResource(id); err != nil {
+			dropped++
+			continue
+		}
+		clean = append(clean, id)
+	}
+	return clean, dropped
+}
+
+// pinnedResourcesWarningHeader formats an RFC 7234 Warning header value
+// reporting that dropped pinned resources were stripped from the
+// response body.
+func pinnedResourcesWarningHeader(dropped int) string {
+	return fmt.Sprintf("199 teleport %q", fmt.Sprintf("%d pinned resource(s) removed: invalid AWS identifier", dropped))
+}
@@ -0,0 +1,130 @@
+/**
+ * Copyright 2024 Gravitational, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateUserPreferences(t *testing.T) {
+	req := UserPreferencesResponse{}
+
+	migrated := migrateUserPreferences(req)
+
+	require.Equal(t, currentUserPreferencesSchemaVersion, migrated.SchemaVersion)
+	require.NotNil(t, migrated.ClusterPreferences.PinnedResources)
+	require.Empty(t, migrated.ClusterPreferences.PinnedResources)
+}
+
+func TestMigrateUserPreferencesIsIdempotent(t *testing.T) {
+	req := UserPreferencesResponse{
+		SchemaVersion: currentUserPreferencesSchemaVersion,
+		ClusterPreferences: ClusterUserPreferencesResponse{
+			PinnedResources: []string{"node/abc"},
+		},
+	}
+
+	migrated := migrateUserPreferences(req)
+
+	require.Equal(t, currentUserPreferencesSchemaVersion, migrated.SchemaVersion)
+	require.Equal(t, []string{"node/abc"}, migrated.ClusterPreferences.PinnedResources)
+}
+
+func TestValidateAWSPinnedResource(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{name: "non-AWS resource id is untouched", id: "node/abc-123"},
+		{name: "valid role ARN", id: "arn:aws:iam::123456789012:role/foo"},
+		{name: "invalid role ARN", id: "arn:aws:iam::123456789012:user/foo", wantErr: true},
+		{name: "valid account", id: "aws-account:123456789012"},
+		{name: "invalid account", id: "aws-account:not-an-account", wantErr: true},
+		{name: "valid region", id: "aws-region:us-east-1"},
+		{name: "invalid region", id: "aws-region:not-a-region", wantErr: true},
+		{name: "valid role name", id: "aws-role:my-role"},
+		{name: "invalid role name", id: "aws-role:has/slash", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAWSPinnedResource(test.id)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSanitizePinnedResources(t *testing.T) {
+	clean, dropped := sanitizePinnedResources([]string{
+		"node/abc-123",
+		"arn:aws:iam::123456789012:role/foo",
+		"aws-region:not-a-region",
+	})
+
+	require.Equal(t, 1, dropped)
+	require.Equal(t, []string{"node/abc-123", "arn:aws:iam::123456789012:role/foo"}, clean)
+}
+
+func TestValidateUserPreferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     UserPreferencesResponse
+		wantErr bool
+	}{
+		{
+			name: "valid preferences",
+			req: UserPreferencesResponse{
+				Assist:             AssistUserPreferencesResponse{PreferredLogins: []string{"root", "ubuntu-user", "svc$"}},
+				ClusterPreferences: ClusterUserPreferencesResponse{PinnedResources: []string{"node/abc-123", "arn:aws:iam::123456789012:role/my-role"}},
+			},
+		},
+		{
+			name: "invalid POSIX login",
+			req: UserPreferencesResponse{
+				Assist: AssistUserPreferencesResponse{PreferredLogins: []string{"not a login!"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid pinned resource id",
+			req: UserPreferencesResponse{
+				ClusterPreferences: ClusterUserPreferencesResponse{PinnedResources: []string{"not a resource id"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateUserPreferences(test.req)
+			if test.wantErr {
+				require.Error(t, err)
+				require.True(t, trace.IsBadParameter(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}